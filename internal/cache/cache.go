@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"php-dep-extractor/internal/parser"
+	"php-dep-extractor/internal/progress"
+	"php-dep-extractor/internal/scanner"
+	"php-dep-extractor/internal/workers"
+)
+
+// formatVersion is bumped whenever the cached data changes meaning, e.g.
+// after parser fixes, so caches written by older versions are discarded.
+const formatVersion = 7
+
+// Entry is what is cached per file.
+type Entry struct {
+	Stat    scanner.FileStat
+	Symbols []scanner.Symbol
+	Refs    []parser.ClassReference
+}
+
+// Cache holds the parse results of a project between scans. Entries are
+// keyed by relative path and trusted while the file's size and
+// modification time are unchanged.
+type Cache struct {
+	Version int
+	Root    string
+	Entries map[string]*Entry
+}
+
+// Stats reports what Refresh did.
+type Stats struct {
+	Parsed  int `json:"parsed"`
+	Reused  int `json:"reused"`
+	Removed int `json:"removed"`
+}
+
+// New returns an empty cache for root.
+func New(root string) *Cache {
+	return &Cache{Version: formatVersion, Root: root, Entries: make(map[string]*Entry)}
+}
+
+// Dir returns the directory cache files are written to.
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "php-dep-extractor"), nil
+}
+
+// fileFor returns the cache file of a project root.
+func fileFor(root string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".gob"), nil
+}
+
+// Load reads the cache of a project root. A missing, unreadable or
+// outdated cache file yields an empty cache.
+func Load(root string) *Cache {
+	path, err := fileFor(root)
+	if err != nil {
+		return New(root)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return New(root)
+	}
+	defer f.Close()
+
+	var c Cache
+	if err := gob.NewDecoder(f).Decode(&c); err != nil || c.Version != formatVersion || c.Root != root {
+		return New(root)
+	}
+	if c.Entries == nil {
+		c.Entries = make(map[string]*Entry)
+	}
+	return &c
+}
+
+// Save writes the cache file. The file is replaced atomically so an
+// interrupted save never leaves a corrupt cache behind.
+func (c *Cache) Save() error {
+	path, err := fileFor(c.Root)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "cache-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(c); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Refresh brings the cache up to date with a scan: new and changed files
+// are parsed, unchanged ones reused and deleted ones dropped. Files that
+// can't be read are left out. When ctx is cancelled the files parsed so far
+// are kept and ctx.Err() is returned.
+func (c *Cache) Refresh(ctx context.Context, result *scanner.ScanResult) (Stats, error) {
+	var stats Stats
+	present := make(map[string]bool, len(result.Files))
+
+	var changed []string
+	for _, relPath := range result.Files {
+		present[relPath] = true
+		if e, ok := c.Entries[relPath]; ok && e.Stat == result.Stats[relPath] {
+			stats.Reused++
+			continue
+		}
+		delete(c.Entries, relPath)
+		changed = append(changed, relPath)
+	}
+
+	entries := make([]*Entry, len(changed))
+	counter := progress.Start(ctx, progress.PhaseParse, len(changed))
+	err := workers.ForEach(ctx, len(changed), func(i int) {
+		defer counter.Done(changed[i])
+		data, err := os.ReadFile(filepath.Join(result.Root, filepath.FromSlash(changed[i])))
+		if err != nil {
+			return
+		}
+		src := string(data)
+		entries[i] = &Entry{
+			Stat:    result.Stats[changed[i]],
+			Symbols: scanner.DeclaredSymbols(src),
+			Refs:    parser.ClassRefs(src),
+		}
+	})
+	for i, e := range entries {
+		if e != nil {
+			c.Entries[changed[i]] = e
+			stats.Parsed++
+		}
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	for relPath := range c.Entries {
+		if !present[relPath] {
+			delete(c.Entries, relPath)
+			stats.Removed++
+		}
+	}
+	return stats, nil
+}
+
+// Symbols returns the cached declarations by relative path.
+func (c *Cache) Symbols() map[string][]scanner.Symbol {
+	m := make(map[string][]scanner.Symbol, len(c.Entries))
+	for relPath, e := range c.Entries {
+		m[relPath] = e.Symbols
+	}
+	return m
+}
+
+// Refs returns the cached class references by relative path.
+func (c *Cache) Refs() map[string][]parser.ClassReference {
+	m := make(map[string][]parser.ClassReference, len(c.Entries))
+	for relPath, e := range c.Entries {
+		m[relPath] = e.Refs
+	}
+	return m
+}
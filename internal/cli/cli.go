@@ -0,0 +1,656 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"php-dep-extractor/internal/cache"
+	"php-dep-extractor/internal/config"
+	"php-dep-extractor/internal/copier"
+	"php-dep-extractor/internal/framework"
+	"php-dep-extractor/internal/graph"
+	"php-dep-extractor/internal/parser"
+	"php-dep-extractor/internal/scanner"
+	"php-dep-extractor/internal/tokens"
+	"php-dep-extractor/internal/workers"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK         = 0
+	ExitError      = 1 // invalid usage, scan or analysis failure
+	ExitCopyErrors = 2 // some files could not be copied
+	ExitUnresolved = 3 // unresolved class references (only with -strict)
+)
+
+const usage = `Usage: php-dep-extractor <command> [options]
+
+Without a command the web UI is started.
+
+Commands:
+  scan        Scan a project and print index statistics
+  analyze     Resolve the dependencies of selected files
+  extract     Resolve dependencies and copy everything to an output directory
+  graph       Print the dependency graph as DOT, Mermaid or GraphML
+  dependents  List the files that reference the selected files
+  version     Print the version
+
+Run "php-dep-extractor <command> -h" for command options.
+`
+
+// stringList is a flag.Value collecting repeated flags.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
+
+// options holds the flags shared by all commands.
+type options struct {
+	root       string
+	framework  string
+	selected   stringList
+	includes   bool
+	transitive bool
+	maxDepth   int
+	out        string
+	format     string
+	budget     int
+	stubs      bool
+	jsonOut    bool
+	strict     bool
+	noCache    bool
+	jobs       int
+	exclude    stringList
+	only       stringList
+	extensions stringList
+	preset     string
+
+	// Not flags: the defaults, or the project config file's settings
+	mappings    []scanner.PrefixMapping
+	baseExclude []string
+	config      string   // path of the project config file, if one was applied
+	includeOnly []string // with -preset: the include targets to export instead of all resolved ones
+}
+
+// report is the JSON document printed with -json.
+type report struct {
+	Root         string                  `json:"root"`
+	Config       string                  `json:"config,omitempty"`
+	FileCount    int                     `json:"fileCount"`
+	Indexed      int                     `json:"indexed"`
+	Functions    int                     `json:"functions"` // global functions indexed besides the classes
+	IndexSource  string                  `json:"indexSource"`
+	Warnings     []string                `json:"warnings,omitempty"` // configuration files the framework couldn't read
+	Cache        *cache.Stats            `json:"cache,omitempty"`
+	Selected     []string                `json:"selected,omitempty"`
+	Dependencies []parser.Dependency     `json:"dependencies,omitempty"`
+	Includes     []parser.IncludeItem    `json:"includes,omitempty"`
+	Unresolved   []parser.ClassReference `json:"unresolved,omitempty"`
+	Tokens       *parser.TokenReport     `json:"tokens,omitempty"`
+	Edges        []parser.Edge           `json:"edges,omitempty"`
+	Dependents   []parser.Dependent      `json:"dependents,omitempty"`
+	Copy         *copier.CopyResult      `json:"copy,omitempty"`
+	OutputDir    string                  `json:"outputDir,omitempty"`
+	OutputFile   string                  `json:"outputFile,omitempty"`
+}
+
+// Run executes a command-line invocation and returns the process exit code.
+func Run(version string, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitError
+	}
+
+	cmd := args[0]
+	switch cmd {
+	case "version", "-version", "--version":
+		fmt.Fprintf(stdout, "php-dep-extractor %s\n", version)
+		return ExitOK
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return ExitOK
+	case "scan", "analyze", "extract", "graph", "dependents":
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", cmd, usage)
+		return ExitError
+	}
+
+	opts, err := parseFlags(cmd, args[1:], stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		fmt.Fprintln(stderr, "Error:", err)
+		return ExitError
+	}
+
+	// Ctrl-C stops scanning and parsing instead of killing the process mid-write
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	code, err := run(ctx, cmd, opts, stdout, stderr)
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(stderr, "Interrupted")
+		return ExitError
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return ExitError
+	}
+	return code
+}
+
+func parseFlags(cmd string, args []string, stderr io.Writer) (*options, error) {
+	opts := &options{
+		mappings:    scanner.DefaultZF1Mappings(),
+		baseExclude: scanner.DefaultExcludes(),
+	}
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	fs.StringVar(&opts.root, "root", ".", "project root directory")
+	fs.StringVar(&opts.framework, "framework", string(scanner.FrameworkZF1), "framework: "+strings.Join(framework.Names(), ", "))
+	fs.BoolVar(&opts.jsonOut, "json", false, "print the result as JSON")
+	fs.BoolVar(&opts.noCache, "no-cache", false, "parse every file instead of reusing the index cache of the last scan")
+	fs.IntVar(&opts.jobs, "jobs", 0, "files read and parsed in parallel (0 = number of CPUs)")
+	fs.Var(&opts.exclude, "exclude", "glob pattern of files or directories to skip, in addition to vendor/ etc. (repeatable or comma-separated)")
+	fs.Var(&opts.extensions, "ext", "file extension to scan, e.g. .phtml (repeatable or comma-separated; default depends on -framework)")
+	fs.Var(&opts.only, "only", "glob pattern; only matching files are scanned (repeatable or comma-separated)")
+	if cmd != "scan" {
+		fs.Var(&opts.selected, "select", "file to analyze, relative to root (repeatable or comma-separated)")
+		fs.StringVar(&opts.preset, "preset", "", "replay a selection preset saved in "+config.FileName+"; -select adds files to it")
+		fs.BoolVar(&opts.transitive, "transitive", false, "follow dependencies of dependencies")
+		fs.IntVar(&opts.maxDepth, "max-depth", 0, "maximum depth in transitive mode (0 = unlimited)")
+	}
+	if cmd != "scan" && cmd != "dependents" {
+		fs.BoolVar(&opts.includes, "includes", false, "parse require/include statements and export resolved targets")
+		fs.BoolVar(&opts.strict, "strict", false, fmt.Sprintf("exit with code %d when class references can't be resolved", ExitUnresolved))
+		fs.IntVar(&opts.budget, "budget", 0, "token budget; the farthest dependencies are dropped until the export fits (0 = no budget)")
+		fs.BoolVar(&opts.stubs, "stubs", false, "with -budget, reduce distant files to signatures before dropping them")
+	}
+	if cmd == "graph" {
+		fs.StringVar(&opts.format, "format", string(graph.FormatDOT), "graph format: dot, mermaid or graphml")
+	}
+	if cmd == "extract" {
+		fs.StringVar(&opts.out, "out", "", "output directory, or output file for bundle and archive formats (- for stdout)")
+		fs.StringVar(&opts.format, "format", "files", "export format: files, markdown, text, xml, zip or tar.gz")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	cfg, err := config.Load(opts.root)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		opts.applyConfig(cfg, fs)
+	}
+	if opts.preset != "" {
+		var p *config.Preset
+		if cfg != nil {
+			p = cfg.Preset(opts.preset)
+		}
+		if p == nil {
+			return nil, fmt.Errorf("no preset %q in %s", opts.preset, config.Path(opts.root))
+		}
+		opts.applyPreset(p, fs)
+	}
+	if cmd != "scan" && len(opts.selected) == 0 {
+		return nil, errors.New("at least one -select file or a -preset is required")
+	}
+	if cmd == "extract" && opts.out == "" {
+		return nil, errors.New("-out is required")
+	}
+	if cmd == "graph" {
+		if _, ok := graph.ParseFormat(opts.format); !ok {
+			return nil, fmt.Errorf("unknown -format %q", opts.format)
+		}
+	} else if cmd == "extract" && opts.format != "files" {
+		_, isBundle := copier.ParseBundleFormat(opts.format)
+		_, isArchive := copier.ParseArchiveFormat(opts.format)
+		if !isBundle && !isArchive {
+			return nil, fmt.Errorf("unknown -format %q", opts.format)
+		}
+	}
+	if framework.Lookup(opts.framework) == nil {
+		return nil, fmt.Errorf("unknown -framework %q", opts.framework)
+	}
+	if opts.maxDepth < 0 || opts.budget < 0 || opts.jobs < 0 {
+		return nil, errors.New("-max-depth, -budget and -jobs must not be negative")
+	}
+	if err := opts.filter().Validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// applyConfig takes the settings of a project config file for every option
+// not given on the command line. -exclude adds to the config's excludes.
+func (o *options) applyConfig(cfg *config.Config, fs *flag.FlagSet) {
+	use := flagUnset(fs)
+
+	o.config = config.Path(o.root)
+	if cfg.Framework != "" && use("framework") {
+		o.framework = string(cfg.Framework)
+	}
+	if cfg.Mappings != nil {
+		o.mappings = cfg.Mappings
+	}
+	if cfg.Extensions != nil && use("ext") {
+		o.extensions = cfg.Extensions
+	}
+	if cfg.Include != nil && use("only") {
+		o.only = cfg.Include
+	}
+	if cfg.Exclude != nil {
+		o.baseExclude = cfg.Exclude
+	}
+
+	o.applyExport(cfg.Export, use)
+}
+
+// applyPreset selects the files of a preset in front of any -select files
+// and takes its options where no flag was given.
+func (o *options) applyPreset(p *config.Preset, fs *flag.FlagSet) {
+	o.selected = append(stringList(slices.Clone(p.Files)), o.selected...)
+	o.includeOnly = p.Includes
+	if o.includeOnly == nil {
+		o.includeOnly = []string{}
+	}
+	o.applyExport(p.Export, flagUnset(fs))
+}
+
+// flagUnset returns a function reporting whether a flag exists for the
+// command but wasn't given on the command line, i.e. whether a setting from
+// the config file may fill it in.
+func flagUnset(fs *flag.FlagSet) func(name string) bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return func(name string) bool {
+		// The graph command's -format is a graph format, not an export format
+		if name == "format" && fs.Name() != "extract" {
+			return false
+		}
+		return fs.Lookup(name) != nil && !set[name]
+	}
+}
+
+// applyExport takes export options for the flags use allows.
+func (o *options) applyExport(e *config.Export, use func(name string) bool) {
+	if e == nil {
+		return
+	}
+	if use("includes") {
+		o.includes = e.ParseIncludes
+	}
+	if use("transitive") {
+		o.transitive = e.Transitive
+	}
+	if use("max-depth") {
+		o.maxDepth = e.MaxDepth
+	}
+	if use("budget") {
+		o.budget = e.Budget
+	}
+	if use("stubs") {
+		o.stubs = e.Stubs
+	}
+	if e.Format != "" && use("format") {
+		o.format = e.Format
+	}
+}
+
+// filter returns what to scan: the -ext extensions or the framework's, the
+// default or configured excludes plus -exclude, and -only.
+func (o *options) filter() scanner.Filter {
+	exts := []string(o.extensions)
+	if len(exts) == 0 {
+		exts = scanner.DefaultExtensions(scanner.Framework(o.framework))
+	}
+	return scanner.Filter{
+		Extensions: exts,
+		Include:    o.only,
+		Exclude:    append(slices.Clip(o.baseExclude), o.exclude...),
+	}
+}
+
+func run(ctx context.Context, cmd string, opts *options, stdout, stderr io.Writer) (int, error) {
+	workers.SetLimit(opts.jobs)
+
+	result, err := scanner.Scan(ctx, opts.root, opts.filter())
+	if err != nil {
+		return ExitError, fmt.Errorf("scan failed: %w", err)
+	}
+
+	c := cache.New(result.Root)
+	if !opts.noCache {
+		c = cache.Load(result.Root)
+	}
+	stats, err := c.Refresh(ctx, result)
+	if !opts.noCache {
+		// Files parsed before an interrupt are kept for the next run
+		c.Save()
+	}
+	if err != nil {
+		return ExitError, fmt.Errorf("scan failed: %w", err)
+	}
+
+	fw := scanner.Framework(opts.framework)
+	index, err := scanner.BuildIndexWith(ctx, result, fw, opts.mappings, c.Symbols())
+	if err != nil {
+		return ExitError, fmt.Errorf("scan failed: %w", err)
+	}
+
+	rep := &report{
+		Root:        filepath.ToSlash(result.Root),
+		Config:      filepath.ToSlash(opts.config),
+		FileCount:   len(result.Files),
+		Indexed:     len(index.ClassToFile),
+		Functions:   len(index.FuncToFile),
+		IndexSource: index.Source,
+		Warnings:    index.Project.Warnings,
+	}
+	if !opts.noCache {
+		rep.Cache = &stats
+	}
+
+	if cmd == "scan" {
+		return ExitOK, printReport(stdout, rep, opts)
+	}
+
+	selected, err := normalizeSelection(opts.selected, result)
+	if err != nil {
+		return ExitError, err
+	}
+	rep.Selected = selected
+
+	if cmd == "dependents" {
+		refs, err := parser.BuildReferenceIndex(ctx, result.Files, index, rep.Root, c.Refs())
+		if err != nil {
+			return ExitError, fmt.Errorf("analysis failed: %w", err)
+		}
+		rep.Dependents = refs.Dependents(selected, parser.ResolveOptions{
+			Transitive: opts.transitive,
+			MaxDepth:   opts.maxDepth,
+		})
+		return ExitOK, printReport(stdout, rep, opts)
+	}
+
+	deps, err := parser.Resolve(ctx, selected, index, rep.Root, parser.ResolveOptions{
+		ParseIncludes: opts.includes,
+		Transitive:    opts.transitive,
+		MaxDepth:      opts.maxDepth,
+		Extensions:    result.Extensions,
+	})
+	if err != nil {
+		return ExitError, fmt.Errorf("analysis failed: %w", err)
+	}
+	rep.Dependencies = deps.Dependencies
+	rep.Includes = deps.Includes
+	rep.Unresolved = deps.Unresolved
+	rep.Edges = deps.Edges
+	includes := exportIncludes(deps, opts.includeOnly)
+	rep.Tokens = parser.EstimateTokens(selected, deps, includes, rep.Root, opts.budget, opts.stubs)
+
+	if cmd == "graph" && !opts.jsonOut {
+		format, _ := graph.ParseFormat(opts.format)
+		if err := graph.Write(stdout, graph.Build(selected, deps), format); err != nil {
+			return ExitError, err
+		}
+		if opts.strict && len(rep.Unresolved) > 0 {
+			return ExitUnresolved, nil
+		}
+		return ExitOK, nil
+	}
+
+	code := ExitOK
+	if cmd == "extract" {
+		files := exportFiles(selected, deps, includes, rep.Tokens.Plan)
+		var filter copier.ContentFilter
+		if rep.Tokens.Plan != nil {
+			filter = parser.StubFilter(rep.Tokens.Plan.Stubbed)
+		}
+		if opts.format == "files" {
+			rep.OutputDir = opts.out
+			rep.Copy = copier.CopyFiles(files, result.Root, filepath.FromSlash(opts.out), filter)
+		} else {
+			toStdout := opts.out == "-"
+			dst := filepath.FromSlash(opts.out)
+			if !toStdout {
+				rep.OutputFile = opts.out
+			}
+
+			if format, ok := copier.ParseBundleFormat(opts.format); ok {
+				if toStdout {
+					rep.Copy, err = copier.WriteBundle(stdout, files, result.Root, format, filter)
+				} else {
+					rep.Copy, err = copier.WriteBundleFile(dst, files, result.Root, format, filter)
+				}
+			} else {
+				format, _ := copier.ParseArchiveFormat(opts.format)
+				if toStdout {
+					rep.Copy, err = copier.WriteArchive(stdout, files, result.Root, format, filter)
+				} else {
+					rep.Copy, err = copier.WriteArchiveFile(dst, files, result.Root, format, filter)
+				}
+			}
+
+			// The export owns stdout; the summary goes to stderr
+			if toStdout {
+				stdout = stderr
+			}
+			if err != nil {
+				return ExitError, fmt.Errorf("export failed: %w", err)
+			}
+		}
+		if len(rep.Copy.Errors) > 0 {
+			code = ExitCopyErrors
+		}
+	}
+	if code == ExitOK && opts.strict && len(rep.Unresolved) > 0 {
+		code = ExitUnresolved
+	}
+
+	return code, printReport(stdout, rep, opts)
+}
+
+// normalizeSelection converts the -select values to scanned relative paths.
+func normalizeSelection(selected []string, result *scanner.ScanResult) ([]string, error) {
+	known := make(map[string]bool, len(result.Files))
+	for _, f := range result.Files {
+		known[f] = true
+	}
+
+	var out []string
+	for _, s := range selected {
+		p := s
+		if filepath.IsAbs(p) {
+			rel, err := filepath.Rel(result.Root, p)
+			if err != nil {
+				return nil, fmt.Errorf("%s is outside the project root", s)
+			}
+			p = rel
+		}
+		p = filepath.ToSlash(filepath.Clean(p))
+		if !known[p] {
+			return nil, fmt.Errorf("%s is not a scanned PHP file", s)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// exportIncludes lists the include targets to export: the resolved
+// includes, or includeOnly if it isn't nil, e.g. those ticked in a preset.
+func exportIncludes(deps *parser.DependencyResult, includeOnly []string) []string {
+	if includeOnly != nil {
+		return includeOnly
+	}
+	var includes []string
+	for _, inc := range deps.Includes {
+		if inc.Resolved != "" {
+			includes = append(includes, inc.Resolved)
+		}
+	}
+	return includes
+}
+
+// exportFiles lists the selected files, their dependencies and the include
+// targets, leaving out files a budget plan dropped.
+func exportFiles(selected []string, deps *parser.DependencyResult, includes []string, plan *tokens.Plan) []string {
+	dropped := make(map[string]bool)
+	if plan != nil {
+		for _, p := range plan.Dropped {
+			dropped[p] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	add := func(p string) {
+		if p != "" && !seen[p] && !dropped[p] {
+			seen[p] = true
+			files = append(files, p)
+		}
+	}
+	for _, f := range selected {
+		add(f)
+	}
+	for _, d := range deps.Dependencies {
+		add(d.FilePath)
+	}
+	for _, p := range includes {
+		add(p)
+	}
+	return files
+}
+
+func printReport(w io.Writer, rep *report, opts *options) error {
+	if opts.jsonOut {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+
+	if rep.Config != "" {
+		fmt.Fprintf(w, "Using settings from %s\n", rep.Config)
+	}
+	source := ""
+	if rep.IndexSource == scanner.IndexSourceComposer {
+		source = " (composer.json autoload)"
+	}
+	reused := ""
+	if rep.Cache != nil && rep.Cache.Reused > 0 {
+		reused = fmt.Sprintf(" (%d unchanged since the last scan)", rep.Cache.Reused)
+	}
+	functions := ""
+	if rep.Functions > 0 {
+		functions = fmt.Sprintf(" and %d functions", rep.Functions)
+	}
+	fmt.Fprintf(w, "Scanned %d files%s, indexed %d classes%s%s\n", rep.FileCount, reused, rep.Indexed, functions, source)
+	for _, warning := range rep.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+	if rep.Selected == nil {
+		return nil
+	}
+
+	// Every analysis has a token report; only the dependents command has none
+	if rep.Tokens == nil {
+		fmt.Fprintf(w, "Selected %d files, found %d dependents\n", len(rep.Selected), len(rep.Dependents))
+		for _, d := range rep.Dependents {
+			// References made by configuration have no line
+			loc := d.FilePath
+			if d.Line > 0 {
+				loc = fmt.Sprintf("%s:%d", d.FilePath, d.Line)
+			}
+			fmt.Fprintf(w, "  %s  %s (%s) -> %s", loc, d.ClassName, d.RefType, d.References)
+			if d.Depth > 1 {
+				fmt.Fprintf(w, " [depth %d]", d.Depth)
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(w, "Selected %d files, found %d dependencies\n", len(rep.Selected), len(rep.Dependencies))
+	for _, d := range rep.Dependencies {
+		fmt.Fprintf(w, "  %s  %s (%s) from %s", d.FilePath, d.ClassName, d.RefType, d.ReferencedBy)
+		if d.Depth > 1 {
+			fmt.Fprintf(w, " [depth %d]", d.Depth)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if t := rep.Tokens; t != nil {
+		fmt.Fprintf(w, "Estimated tokens: %d\n", t.Total)
+		if p := t.Plan; p != nil {
+			fit := "fits"
+			if !p.Fits {
+				fit = "exceeds"
+			}
+			fmt.Fprintf(w, "Budget %d: %d tokens %s, %d kept, %d stubbed, %d dropped\n",
+				p.Budget, p.Total, fit, len(p.Keep), len(p.Stubbed), len(p.Dropped))
+			for _, f := range p.Stubbed {
+				fmt.Fprintf(w, "  stubbed  %s\n", f)
+			}
+			for _, f := range p.Dropped {
+				fmt.Fprintf(w, "  dropped  %s\n", f)
+			}
+		}
+	}
+
+	if len(rep.Includes) > 0 {
+		fmt.Fprintf(w, "Includes: %d\n", len(rep.Includes))
+		for _, inc := range rep.Includes {
+			target := inc.Resolved
+			if target == "" {
+				target = inc.RawPath + " (unresolved)"
+			}
+			fmt.Fprintf(w, "  %s:%d  %s %s\n", inc.SourceFile, inc.Line, inc.Type, target)
+		}
+	}
+
+	if len(rep.Unresolved) > 0 {
+		fmt.Fprintf(w, "Unresolved references: %d\n", len(rep.Unresolved))
+		for _, ref := range rep.Unresolved {
+			fmt.Fprintf(w, "  %s:%d  %s (%s)\n", ref.SourceFile, ref.Line, ref.ClassName, ref.RefType)
+		}
+	}
+
+	if rep.Copy != nil {
+		switch {
+		case rep.OutputDir != "":
+			fmt.Fprintf(w, "Copied %d files to %s\n", len(rep.Copy.Copied), rep.OutputDir)
+		case rep.OutputFile != "":
+			fmt.Fprintf(w, "Exported %d files to %s\n", len(rep.Copy.Copied), rep.OutputFile)
+		default:
+			fmt.Fprintf(w, "Exported %d files\n", len(rep.Copy.Copied))
+		}
+		for _, e := range rep.Copy.Errors {
+			fmt.Fprintf(w, "  error: %s\n", e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"php-dep-extractor/internal/scanner"
+)
+
+// FileName is the project configuration file looked for at the scan root.
+const FileName = ".pde.json"
+
+// Config is the project configuration, meant to be checked into the
+// repository so every teammate scans with the same settings. Fields left
+// out keep the settings of whoever scans.
+type Config struct {
+	Framework  scanner.Framework       `json:"framework,omitempty"`
+	Mappings   []scanner.PrefixMapping `json:"mappings,omitempty"`
+	Extensions []string                `json:"extensions,omitempty"`
+	Include    []string                `json:"include,omitempty"`
+	Exclude    []string                `json:"exclude,omitempty"`
+	Export     *Export                 `json:"export,omitempty"`
+	Presets    []Preset                `json:"presets,omitempty"`
+}
+
+// Preset is a saved selection, replayed to regenerate the same export.
+type Preset struct {
+	Name     string   `json:"name"`
+	Files    []string `json:"files"`              // seed files, relative to the root
+	Includes []string `json:"includes,omitempty"` // resolved include targets ticked for export
+	Export   *Export  `json:"export,omitempty"`   // options; nil keeps the current ones
+}
+
+// Export holds the analysis and export options.
+type Export struct {
+	ParseIncludes bool   `json:"parseIncludes,omitempty"`
+	Transitive    bool   `json:"transitive,omitempty"`
+	MaxDepth      int    `json:"maxDepth,omitempty"`
+	Budget        int    `json:"budget,omitempty"`
+	Stubs         bool   `json:"stubs,omitempty"`
+	Format        string `json:"format,omitempty"` // export format, e.g. "files" or "markdown"
+}
+
+// Path returns the config file path of a project root.
+func Path(root string) string {
+	return filepath.Join(root, FileName)
+}
+
+// Load reads the config file of a project root. It returns nil without
+// error when the file doesn't exist.
+func Load(root string) (*Config, error) {
+	data, err := os.ReadFile(Path(root))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	f := scanner.Filter{Include: c.Include, Exclude: c.Exclude}
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	if !c.Export.valid() {
+		return nil, fmt.Errorf("invalid %s: maxDepth and budget must not be negative", FileName)
+	}
+	for _, p := range c.Presets {
+		if p.Name == "" || len(p.Files) == 0 || !p.Export.valid() {
+			return nil, fmt.Errorf("invalid %s: preset %q needs a name, files and valid options", FileName, p.Name)
+		}
+	}
+	return &c, nil
+}
+
+// valid reports whether the options are usable; nil options are.
+func (e *Export) valid() bool {
+	return e == nil || e.MaxDepth >= 0 && e.Budget >= 0
+}
+
+// Preset returns the preset with the given name, or nil.
+func (c *Config) Preset(name string) *Preset {
+	for i := range c.Presets {
+		if c.Presets[i].Name == name {
+			return &c.Presets[i]
+		}
+	}
+	return nil
+}
+
+// SetPreset adds a preset, replacing one with the same name.
+func (c *Config) SetPreset(p Preset) {
+	if old := c.Preset(p.Name); old != nil {
+		*old = p
+		return
+	}
+	c.Presets = append(c.Presets, p)
+}
+
+// RemovePreset deletes a preset and reports whether it existed.
+func (c *Config) RemovePreset(name string) bool {
+	for i := range c.Presets {
+		if c.Presets[i].Name == name {
+			c.Presets = append(c.Presets[:i], c.Presets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the config file of a project root, indented for diffs.
+func (c *Config) Save(root string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(Path(root), append(data, '\n'), 0644)
+}
+
+// ApplyFilter returns f with the extensions and patterns the config sets.
+func (c *Config) ApplyFilter(f scanner.Filter) scanner.Filter {
+	if c.Extensions != nil {
+		f.Extensions = c.Extensions
+	}
+	if c.Include != nil {
+		f.Include = c.Include
+	}
+	if c.Exclude != nil {
+		f.Exclude = c.Exclude
+	}
+	return f
+}
@@ -0,0 +1,190 @@
+package copier
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat selects the archive type written by WriteArchive.
+type ArchiveFormat string
+
+const (
+	ArchiveZip   ArchiveFormat = "zip"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+)
+
+// ManifestName is the archive entry listing the exported files.
+const ManifestName = "pde-manifest.json"
+
+// ParseArchiveFormat validates a format name. "tgz" is accepted as an alias.
+func ParseArchiveFormat(s string) (ArchiveFormat, bool) {
+	switch strings.ToLower(s) {
+	case "zip":
+		return ArchiveZip, true
+	case "tar.gz", "tgz":
+		return ArchiveTarGz, true
+	}
+	return "", false
+}
+
+// Ext returns the file extension for the format, including the dot.
+func (f ArchiveFormat) Ext() string {
+	if f == ArchiveTarGz {
+		return ".tar.gz"
+	}
+	return ".zip"
+}
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	Created time.Time       `json:"created"`
+	Root    string          `json:"root"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes one archived file.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// archiveWriter abstracts over zip and tar so both share one export loop.
+type archiveWriter interface {
+	add(name string, modTime time.Time, data []byte) error
+	close() error
+}
+
+// WriteArchive writes the given relative paths from srcRoot into a zip or
+// tar.gz archive, preserving their relative paths, followed by a manifest
+// entry. Unreadable files are reported in Errors and skipped. filter may be
+// nil.
+func WriteArchive(w io.Writer, files []string, srcRoot string, format ArchiveFormat, filter ContentFilter) (*CopyResult, error) {
+	var aw archiveWriter
+	switch format {
+	case ArchiveZip:
+		aw = &zipArchive{zw: zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		aw = &tarArchive{gz: gz, tw: tar.NewWriter(gz)}
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", format)
+	}
+
+	result := &CopyResult{}
+	manifest := Manifest{Created: time.Now().UTC(), Root: filepath.Base(srcRoot)}
+
+	for _, relPath := range files {
+		srcPath := filepath.Join(srcRoot, filepath.FromSlash(relPath))
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("stat %s: %v", relPath, err))
+			continue
+		}
+		data, err := os.ReadFile(srcPath)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("read %s: %v", relPath, err))
+			continue
+		}
+		if filter != nil {
+			data = []byte(filter(relPath, string(data)))
+		}
+		if err := aw.add(relPath, info.ModTime(), data); err != nil {
+			return nil, fmt.Errorf("write %s: %w", relPath, err)
+		}
+
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, ManifestEntry{
+			Path:   relPath,
+			Size:   int64(len(data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+		result.Copied = append(result.Copied, relPath)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := aw.add(ManifestName, manifest.Created, data); err != nil {
+		return nil, fmt.Errorf("write manifest: %w", err)
+	}
+
+	return result, aw.close()
+}
+
+// WriteArchiveFile writes an archive to dstPath, creating parent directories.
+func WriteArchiveFile(dstPath string, files []string, srcRoot string, format ArchiveFormat, filter ContentFilter) (*CopyResult, error) {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return nil, err
+	}
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	result, err := WriteArchive(out, files, srcRoot, format, filter)
+	if err != nil {
+		return nil, err
+	}
+	return result, out.Close()
+}
+
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) add(name string, modTime time.Time, data []byte) error {
+	f, err := a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func (a *zipArchive) close() error {
+	return a.zw.Close()
+}
+
+type tarArchive struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (a *tarArchive) add(name string, modTime time.Time, data []byte) error {
+	err := a.tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = a.tw.Write(data)
+	return err
+}
+
+func (a *tarArchive) close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
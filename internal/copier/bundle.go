@@ -0,0 +1,154 @@
+package copier
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"php-dep-extractor/internal/filetree"
+)
+
+// BundleFormat selects the layout of a single-document export.
+type BundleFormat string
+
+const (
+	BundleMarkdown BundleFormat = "markdown"
+	BundleText     BundleFormat = "text"
+	BundleXML      BundleFormat = "xml"
+)
+
+// ParseBundleFormat validates a format name. "md" and "txt" are accepted as aliases.
+func ParseBundleFormat(s string) (BundleFormat, bool) {
+	switch strings.ToLower(s) {
+	case "markdown", "md":
+		return BundleMarkdown, true
+	case "text", "txt":
+		return BundleText, true
+	case "xml":
+		return BundleXML, true
+	}
+	return "", false
+}
+
+// Ext returns the file extension for the format, including the dot.
+func (f BundleFormat) Ext() string {
+	switch f {
+	case BundleText:
+		return ".txt"
+	case BundleXML:
+		return ".xml"
+	}
+	return ".md"
+}
+
+// ContentType returns the MIME type used when serving the format.
+func (f BundleFormat) ContentType() string {
+	switch f {
+	case BundleText:
+		return "text/plain; charset=utf-8"
+	case BundleXML:
+		return "application/xml; charset=utf-8"
+	}
+	return "text/markdown; charset=utf-8"
+}
+
+// Languages for fenced code blocks by file extension.
+var fenceLanguages = map[string]string{
+	".php": "php", ".phtml": "php", ".inc": "php", ".module": "php", ".ctp": "php",
+	".js": "javascript", ".json": "json", ".yaml": "yaml", ".yml": "yaml",
+	".twig": "twig", ".html": "html", ".xml": "xml", ".css": "css", ".sql": "sql",
+}
+
+// WriteBundle writes the given relative paths from srcRoot into one document:
+// a file-tree header followed by every file labelled with its relative path.
+// In the XML format file contents are not escaped, which is the convention
+// for XML-tagged AI prompts. Unreadable files are reported in Errors and skipped.
+// filter may be nil.
+func WriteBundle(w io.Writer, files []string, srcRoot string, format BundleFormat, filter ContentFilter) (*CopyResult, error) {
+	result := &CopyResult{}
+	bw := bufio.NewWriter(w)
+
+	tree := filetree.Format(filetree.Build(files))
+	switch format {
+	case BundleMarkdown:
+		fmt.Fprintf(bw, "# Code bundle\n\n%d files\n\n```text\n%s```\n", len(files), tree)
+	case BundleText:
+		fmt.Fprintf(bw, "Code bundle: %d files\n\n%s", len(files), tree)
+	case BundleXML:
+		fmt.Fprintf(bw, "<bundle files=\"%d\">\n<tree>\n%s</tree>\n", len(files), tree)
+	default:
+		return nil, fmt.Errorf("unknown bundle format %q", format)
+	}
+
+	for _, relPath := range files {
+		data, err := os.ReadFile(filepath.Join(srcRoot, filepath.FromSlash(relPath)))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("read %s: %v", relPath, err))
+			continue
+		}
+		content := strings.ReplaceAll(string(data), "\r\n", "\n")
+		if filter != nil {
+			content = filter(relPath, content)
+		}
+		content = strings.TrimRight(content, "\n")
+
+		switch format {
+		case BundleMarkdown:
+			fence := codeFence(content)
+			lang := fenceLanguages[strings.ToLower(path.Ext(relPath))]
+			fmt.Fprintf(bw, "\n## %s\n\n%s%s\n%s\n%s\n", relPath, fence, lang, content, fence)
+		case BundleText:
+			fmt.Fprintf(bw, "\n==== %s ====\n%s\n", relPath, content)
+		case BundleXML:
+			fmt.Fprintf(bw, "<file path=\"%s\">\n%s\n</file>\n", html.EscapeString(relPath), content)
+		}
+		result.Copied = append(result.Copied, relPath)
+	}
+
+	if format == BundleXML {
+		bw.WriteString("</bundle>\n")
+	}
+	return result, bw.Flush()
+}
+
+// WriteBundleFile writes a bundle to dstPath, creating parent directories.
+func WriteBundleFile(dstPath string, files []string, srcRoot string, format BundleFormat, filter ContentFilter) (*CopyResult, error) {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return nil, err
+	}
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	result, err := WriteBundle(out, files, srcRoot, format, filter)
+	if err != nil {
+		return nil, err
+	}
+	return result, out.Close()
+}
+
+// codeFence returns a backtick fence longer than any backtick run in content.
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, c := range content {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
@@ -13,8 +13,12 @@ type CopyResult struct {
 	Errors []string `json:"errors"`
 }
 
+// ContentFilter rewrites a file's content before it is exported, e.g. to stub it.
+type ContentFilter func(relPath, content string) string
+
 // CopyFiles copies the given relative paths from srcRoot to dstRoot, preserving directory structure.
-func CopyFiles(files []string, srcRoot string, dstRoot string) *CopyResult {
+// filter may be nil.
+func CopyFiles(files []string, srcRoot string, dstRoot string, filter ContentFilter) *CopyResult {
 	result := &CopyResult{}
 
 	for _, relPath := range files {
@@ -29,7 +33,7 @@ func CopyFiles(files []string, srcRoot string, dstRoot string) *CopyResult {
 		}
 
 		// Copy file
-		if err := copyFile(srcPath, dstPath); err != nil {
+		if err := copyFile(srcPath, dstPath, relPath, filter); err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("copy %s: %v", relPath, err))
 			continue
 		}
@@ -40,7 +44,15 @@ func CopyFiles(files []string, srcRoot string, dstRoot string) *CopyResult {
 	return result
 }
 
-func copyFile(src, dst string) error {
+func copyFile(src, dst, relPath string, filter ContentFilter) error {
+	if filter != nil {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(dst, []byte(filter(relPath, string(data))), 0644)
+	}
+
 	in, err := os.Open(src)
 	if err != nil {
 		return err
@@ -70,3 +70,29 @@ func sortTree(node *TreeNode) {
 		sortTree(child)
 	}
 }
+
+// Format renders the tree as indented text using box-drawing characters,
+// e.g. for the header of an exported bundle.
+func Format(root *TreeNode) string {
+	var sb strings.Builder
+	var walk func(node *TreeNode, prefix string)
+	walk = func(node *TreeNode, prefix string) {
+		for i, child := range node.Children {
+			branch, indent := "├── ", "│   "
+			if i == len(node.Children)-1 {
+				branch, indent = "└── ", "    "
+			}
+			name := child.Name
+			if child.IsDir {
+				name += "/"
+			}
+			sb.WriteString(prefix + branch + name + "\n")
+			if child.IsDir {
+				walk(child, prefix+indent)
+			}
+		}
+	}
+	sb.WriteString(".\n")
+	walk(root, "")
+	return sb.String()
+}
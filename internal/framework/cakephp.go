@@ -0,0 +1,62 @@
+package framework
+
+import (
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// cakePHP covers CakePHP 2.x and 3+: the class is named after the file,
+// and 2.x loads classes with App::uses() and App::import().
+type cakePHP struct{ Base }
+
+func (cakePHP) Name() string  { return "cakephp" }
+func (cakePHP) Label() string { return "CakePHP" }
+
+func (cakePHP) Extensions() []string {
+	return []string{".php", ".ctp", ".inc"}
+}
+
+// ClassFromPath derives class name from CakePHP path conventions.
+func (cakePHP) ClassFromPath(relPath string, _ []PrefixMapping) string {
+	p := strings.TrimSuffix(relPath, ".php")
+
+	// CakePHP 2.x: app/Model/Post.php -> Post
+	prefixes := []string{"app/", "src/"}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(p, prefix) {
+			rest := strings.TrimPrefix(p, prefix)
+			// The class name is the filename (last component)
+			parts := strings.Split(rest, "/")
+			if len(parts) > 0 {
+				return parts[len(parts)-1]
+			}
+		}
+	}
+	return ""
+}
+
+// RefsAt finds App::uses('Class', 'Type') and App::import('Type', 'Class').
+func (cakePHP) RefsAt(toks []lexer.Token, i int) []Reference {
+	at := func(j int) lexer.Token {
+		if j >= len(toks) {
+			return lexer.Token{Kind: lexer.Whitespace}
+		}
+		return toks[j]
+	}
+	if toks[i].Text != "App" || !at(i+1).Is("::") || !at(i+3).Is("(") {
+		return nil
+	}
+	arg1 := at(i + 4)
+	switch {
+	case at(i+2).Is("uses") && arg1.Kind == lexer.String:
+		return []Reference{{Name: arg1.StringValue(), RefType: "uses", Line: arg1.Line}}
+	case at(i+2).Is("import") && arg1.Kind == lexer.String && at(i+5).Is(",") && at(i+6).Kind == lexer.String:
+		return []Reference{{Name: at(i + 6).StringValue(), RefType: "import", Line: at(i + 6).Line}}
+	}
+	return nil
+}
+
+func (cakePHP) CorePrefixes() []string {
+	return []string{"Cake"}
+}
@@ -0,0 +1,185 @@
+package framework
+
+import (
+	"path"
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// codeIgniter covers CodeIgniter 3, whose classes are named after their
+// file under application/ and are loaded with $this->load->model() and
+// friends, and CodeIgniter 4, which maps App\ to app/ and loads with
+// model(), view() and helper().
+type codeIgniter struct{ Base }
+
+// Loader methods of CodeIgniter 3 and the loader functions of 4.
+var (
+	ciLoaders   = map[string]string{"model": RefModel, "library": RefLibrary, "helper": RefHelper, "view": RefView}
+	ciFunctions = map[string]string{"model": RefModel, "helper": RefHelper, "view": RefView}
+)
+
+// Directories loaded from, by reference type. Earlier ones win when a name
+// is found in several.
+var ciDirs = []struct {
+	refType string
+	dirs    []string
+}{
+	{RefModel, []string{"application/models/", "app/Models/"}},
+	{RefLibrary, []string{"application/libraries/", "app/Libraries/", "system/libraries/"}},
+	{RefHelper, []string{"application/helpers/", "app/Helpers/", "system/helpers/", "system/Helpers/"}},
+	{RefView, []string{"application/views/", "app/Views/"}},
+}
+
+// Class directories of CodeIgniter 3, where the class is named after the
+// file, and the directories of CodeIgniter 4's app/ that hold no classes.
+var (
+	ci3ClassDirs = []string{"application/controllers/", "application/models/", "application/libraries/", "application/core/"}
+	ci4PlainDirs = []string{"app/Views/", "app/Helpers/", "app/Language/"}
+)
+
+func (codeIgniter) Name() string  { return "codeigniter" }
+func (codeIgniter) Label() string { return "CodeIgniter" }
+
+// ClassFromPath derives class name from CodeIgniter path conventions.
+// e.g. "application/models/admin/User_model.php" -> "User_model" (3) and
+// "app/Models/UserModel.php" -> "App\Models\UserModel" (4)
+func (codeIgniter) ClassFromPath(relPath string, _ []PrefixMapping) string {
+	p := strings.TrimSuffix(relPath, ".php")
+	if rest, ok := strings.CutPrefix(p, "app/"); ok {
+		for _, dir := range ci4PlainDirs {
+			if strings.HasPrefix(p, dir) {
+				return ""
+			}
+		}
+		return "App\\" + strings.ReplaceAll(rest, "/", "\\")
+	}
+	for _, dir := range ci3ClassDirs {
+		if strings.HasPrefix(p, dir) {
+			return path.Base(p)
+		}
+	}
+	return ""
+}
+
+// RefsAt finds loader calls, e.g. $this->load->model('user_model') or
+// $this->load->library(array('email', 'upload')) in CodeIgniter 3 and
+// view('welcome_message') or helper(['form', 'url']) in 4.
+func (codeIgniter) RefsAt(toks []lexer.Token, i int) []Reference {
+	at := func(j int) lexer.Token {
+		if j < 0 || j >= len(toks) {
+			return lexer.Token{Kind: lexer.Whitespace}
+		}
+		return toks[j]
+	}
+
+	var refType string
+	var open int
+	switch prev := at(i - 1); {
+	case toks[i].Is("load") && prev.Is("->") && at(i+1).Is("->") && at(i+3).Is("("):
+		refType, open = ciLoaders[strings.ToLower(at(i+2).Text)], i+3
+	case toks[i].Kind == lexer.Ident && at(i+1).Is("("):
+		// Methods and declarations of the same name aren't loader calls
+		if prev.Is("->") || prev.Is("?->") || prev.Is("::") || prev.Is("function") || prev.Is("new") {
+			return nil
+		}
+		refType, open = ciFunctions[strings.ToLower(toks[i].Text)], i+1
+	}
+	if refType == "" {
+		return nil
+	}
+
+	var refs []Reference
+	for _, arg := range loaderArgs(toks, open) {
+		if name := ciName(arg.StringValue(), refType); name != "" {
+			refs = append(refs, Reference{Name: name, RefType: refType, Line: arg.Line})
+		}
+	}
+	return refs
+}
+
+// loaderArgs returns the names passed to the loader call opened at
+// toks[open]: a string or a list of strings. Keys of array('model' =>
+// 'alias') are names, the aliases aren't.
+func loaderArgs(toks []lexer.Token, open int) []lexer.Token {
+	if open+1 >= len(toks) {
+		return nil
+	}
+	arg := toks[open+1]
+	if arg.Kind == lexer.String {
+		return []lexer.Token{arg}
+	}
+
+	start := open + 1
+	if arg.Is("array") && start+1 < len(toks) && toks[start+1].Is("(") {
+		start++
+	} else if !arg.Is("[") {
+		return nil
+	}
+	var names []lexer.Token
+	depth := 0
+	for j := start; j < len(toks); j++ {
+		t := toks[j]
+		switch {
+		case t.Is("(") || t.Is("["):
+			depth++
+		case t.Is(")") || t.Is("]"):
+			depth--
+			if depth == 0 {
+				return names
+			}
+		case depth == 1 && t.Kind == lexer.String && !toks[j-1].Is("=>"):
+			names = append(names, t)
+		}
+	}
+	return names
+}
+
+// ciName normalizes a loaded name to the key of Project.Names: lower case,
+// slash-separated and without .php or the _helper suffix, e.g.
+// "Admin/User_model" -> "admin/user_model" and "url_helper" -> "url".
+func ciName(name, refType string) string {
+	n := strings.ToLower(strings.Trim(strings.ReplaceAll(name, "\\", "/"), "/"))
+	n = strings.TrimSuffix(n, ".php")
+	if refType == RefHelper {
+		n = strings.TrimSuffix(n, "_helper")
+	}
+	return n
+}
+
+func (codeIgniter) CorePrefixes() []string {
+	return []string{"CI_", "CodeIgniter\\"}
+}
+
+// Project maps the names loaders take to the files under the model,
+// library, helper and view directories. A library in a folder of its own
+// name, like system/libraries/Session/Session.php, is also found by the
+// folder name.
+func (codeIgniter) Project(idx Index) Project {
+	p := Project{Names: make(map[string]map[string]string)}
+	for _, loader := range ciDirs {
+		names := make(map[string]string)
+		add := func(key, file string) {
+			if _, ok := names[key]; !ok {
+				names[key] = file
+			}
+		}
+		for _, dir := range loader.dirs {
+			for _, f := range idx.Files {
+				rest, ok := strings.CutPrefix(f, dir)
+				if !ok || !strings.HasSuffix(rest, ".php") {
+					continue
+				}
+				key := ciName(rest, loader.refType)
+				add(key, f)
+				// CodeIgniter 4 also takes the class name: model('App\Models\UserModel')
+				add(strings.ToLower(dir)+key, f)
+				if d, base := path.Split(key); d != "" && path.Base(d) == base {
+					add(strings.TrimSuffix(d, "/"), f)
+				}
+			}
+		}
+		p.Names[loader.refType] = names
+	}
+	return p
+}
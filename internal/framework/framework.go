@@ -0,0 +1,168 @@
+package framework
+
+import (
+	"php-dep-extractor/internal/lexer"
+)
+
+// Framework describes the conventions of a PHP framework: how files and
+// classes are named and which references only the framework reveals.
+// Implementations embed Base for the parts they don't customise and are
+// added with Register.
+type Framework interface {
+	// Name is the identifier used in settings, flags and .pde.json, e.g. "zf1".
+	Name() string
+	// Label is the display name.
+	Label() string
+	// Extensions are the file extensions scanned by default.
+	Extensions() []string
+	// ClassFromPath derives the class a .php file declares by convention,
+	// or "" if the path doesn't follow one.
+	ClassFromPath(relPath string, mappings []PrefixMapping) string
+	// CandidatePaths lists files that may declare a class the index doesn't
+	// know under the referenced name, e.g. a ZF1 class used without its
+	// prefix.
+	CandidatePaths(className string, mappings []PrefixMapping) []string
+	// RefsAt returns the references starting at toks[i] that only the
+	// framework's conventions reveal, e.g. CakePHP's App::uses().
+	RefsAt(toks []lexer.Token, i int) []Reference
+	// CorePrefixes are class name prefixes of the framework itself. Such
+	// classes are never part of a project and aren't reported.
+	CorePrefixes() []string
+	// Project reads what a project configures besides its declarations.
+	Project(idx Index) Project
+	// IncludePath resolves an include expression built with the
+	// framework's idioms to a path relative to the root, or returns "".
+	// relPath is the including file.
+	IncludePath(expr, relPath string) string
+}
+
+// PrefixMapping maps a class prefix to a directory (for ZF1 style).
+type PrefixMapping struct {
+	Prefix string `json:"prefix"`
+	Dir    string `json:"dir"`
+}
+
+// Reference is a class reference found by a framework.
+type Reference struct {
+	Name    string // class name as written in the source, or a template or route name
+	RefType string
+	Line    int // 0 for references made by configuration
+}
+
+// Reference types that name something other than a class. They are looked
+// up in Project.Names instead of the class index.
+const (
+	RefTemplate = "template"
+	RefRoute    = "route"
+	RefFunction = "function" // a call of a global function
+	RefHook     = "hook"     // a function registered as a hook callback
+
+	// Files loaded by name, e.g. with CodeIgniter's $this->load->view()
+	RefModel   = "model"
+	RefLibrary = "library"
+	RefHelper  = "helper"
+	RefView    = "view"
+
+	// Yii2 path aliases like "@app/views/site/index", application
+	// components like Yii::$app->mailer and class names in config arrays
+	RefAlias     = "alias"
+	RefComponent = "component"
+	RefClassName = "class"
+)
+
+// IsNameRef reports whether references of refType name something other
+// than a class.
+func IsNameRef(refType string) bool {
+	switch refType {
+	case RefTemplate, RefRoute, RefFunction, RefHook, RefModel, RefLibrary, RefHelper, RefView,
+		RefAlias, RefComponent, RefClassName:
+		return true
+	}
+	return false
+}
+
+// Index is what the class index knows when a framework reads a project.
+type Index struct {
+	Root        string
+	Files       []string          // scanned files, relative to Root
+	ClassToFile map[string]string // class name -> relative path
+	FuncToFile  map[string]string // global function name -> relative path
+}
+
+// Project is what a framework reads from a project's configuration.
+type Project struct {
+	// Refs are references configuration adds to files, e.g. the services
+	// a service definition injects, by relative path
+	Refs map[string][]Reference
+	// Names maps a name reference type to the names and the files behind
+	// them, e.g. RefTemplate -> "blog/index.html.twig" ->
+	// "templates/blog/index.html.twig". Reference types without an entry
+	// aren't used by the framework and are ignored.
+	Names map[string]map[string]string
+	// Optional are the name reference types whose unknown names aren't
+	// reported as unresolved, e.g. calls that mostly go to PHP itself
+	Optional map[string]bool
+	// Warnings name configuration files that couldn't be read, e.g.
+	// "config/services.yaml skipped: line 3: unknown alias *x"
+	Warnings []string
+}
+
+// Base implements the optional parts of Framework: plain .php files, no
+// naming conventions, extra references, core classes, configuration or
+// include idioms.
+type Base struct{}
+
+func (Base) Extensions() []string                            { return []string{".php"} }
+func (Base) ClassFromPath(string, []PrefixMapping) string    { return "" }
+func (Base) CandidatePaths(string, []PrefixMapping) []string { return nil }
+func (Base) RefsAt([]lexer.Token, int) []Reference           { return nil }
+func (Base) CorePrefixes() []string                          { return nil }
+func (Base) Project(Index) Project                           { return Project{} }
+func (Base) IncludePath(string, string) string               { return "" }
+
+// registry holds the frameworks in registration order, which is the order
+// they are offered in.
+var registry []Framework
+
+func init() {
+	Register(zf1{})
+	Register(cakePHP{})
+	Register(laravel{})
+	Register(symfony{})
+	Register(wordPress{})
+	Register(codeIgniter{})
+	Register(yii2{})
+}
+
+// Register adds a framework. It must be called from an init function; a
+// duplicate name panics.
+func Register(fw Framework) {
+	if Lookup(fw.Name()) != nil {
+		panic("framework: duplicate name " + fw.Name())
+	}
+	registry = append(registry, fw)
+}
+
+// Lookup returns the framework with the given name, or nil.
+func Lookup(name string) Framework {
+	for _, fw := range registry {
+		if fw.Name() == name {
+			return fw
+		}
+	}
+	return nil
+}
+
+// All returns the registered frameworks.
+func All() []Framework {
+	return registry
+}
+
+// Names returns the names of the registered frameworks.
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, fw := range registry {
+		names[i] = fw.Name()
+	}
+	return names
+}
@@ -0,0 +1,27 @@
+package framework
+
+import (
+	"strings"
+)
+
+// laravel follows PSR-4 with the App\ namespace mapped to app/.
+type laravel struct{ Base }
+
+func (laravel) Name() string  { return "laravel" }
+func (laravel) Label() string { return "Laravel" }
+
+// ClassFromPath derives fully-qualified class name from Laravel PSR-4 conventions.
+func (laravel) ClassFromPath(relPath string, _ []PrefixMapping) string {
+	p := strings.TrimSuffix(relPath, ".php")
+
+	// app/Models/User.php -> App\Models\User
+	if strings.HasPrefix(p, "app/") {
+		rest := strings.TrimPrefix(p, "app/")
+		return "App\\" + strings.ReplaceAll(rest, "/", "\\")
+	}
+	return ""
+}
+
+func (laravel) CorePrefixes() []string {
+	return []string{"Illuminate\\"}
+}
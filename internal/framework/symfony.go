@@ -0,0 +1,390 @@
+package framework
+
+import (
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// symfony follows the PSR-4 mapping of the Symfony skeleton, App\ in src/,
+// and reads the service definitions and routes of the project.
+type symfony struct{ Base }
+
+// RefService is the reference type of services injected by configuration.
+const RefService = "service"
+
+// Calls naming a template or route in their first argument.
+var (
+	templateCalls = map[string]bool{
+		"render": true, "renderView": true, "renderForm": true,
+		"renderBlock": true, "renderBlockView": true, "stream": true,
+		"Template": true, // #[Template('blog/index.html.twig')]
+	}
+	routeCalls = map[string]bool{"redirectToRoute": true, "generateUrl": true}
+)
+
+// routeAnnotation finds the name of a @Route annotation.
+var routeAnnotation = regexp.MustCompile(`@Route\s*\([^)]*?\bname\s*=\s*"([^"]+)"`)
+
+func (symfony) Name() string  { return "symfony" }
+func (symfony) Label() string { return "Symfony" }
+
+func (symfony) Extensions() []string {
+	return []string{".php", ".twig"}
+}
+
+// ClassFromPath derives class name from the skeleton's PSR-4 mapping.
+// e.g. "src/Controller/BlogController.php" -> "App\Controller\BlogController"
+func (symfony) ClassFromPath(relPath string, _ []PrefixMapping) string {
+	rest, ok := strings.CutPrefix(strings.TrimSuffix(relPath, ".php"), "src/")
+	if !ok {
+		return ""
+	}
+	return "App\\" + strings.ReplaceAll(rest, "/", "\\")
+}
+
+// RefsAt finds templates and routes named by controller calls, e.g.
+// $this->render('blog/index.html.twig') or $this->redirectToRoute('blog_show').
+func (symfony) RefsAt(toks []lexer.Token, i int) []Reference {
+	if toks[i].Kind != lexer.Ident || i+2 >= len(toks) || !toks[i+1].Is("(") || toks[i+2].Kind != lexer.String {
+		return nil
+	}
+	arg := toks[i+2]
+	name := arg.StringValue()
+	switch {
+	case templateCalls[toks[i].Text] && strings.HasSuffix(name, ".twig"):
+		return []Reference{{Name: name, RefType: RefTemplate, Line: arg.Line}}
+	case routeCalls[toks[i].Text] && name != "":
+		return []Reference{{Name: name, RefType: RefRoute, Line: arg.Line}}
+	}
+	return nil
+}
+
+func (symfony) CorePrefixes() []string {
+	return []string{"Symfony\\"}
+}
+
+// Project reads the templates under templates/, the services of
+// config/services*.yaml and the routes of config/routes*.yaml and the
+// controllers they load.
+func (symfony) Project(idx Index) Project {
+	p := Project{
+		Refs:  make(map[string][]Reference),
+		Names: map[string]map[string]string{RefTemplate: {}, RefRoute: {}},
+	}
+	for _, f := range idx.Files {
+		if name, ok := strings.CutPrefix(f, "templates/"); ok && strings.HasSuffix(name, ".twig") {
+			p.Names[RefTemplate][name] = f
+		}
+	}
+	readServices(idx.Root, idx.Files, idx.ClassToFile, &p)
+	readRoutes(idx.Root, idx.Files, idx.ClassToFile, p.Names[RefRoute], &p.Warnings)
+	return p
+}
+
+// readServices adds a reference from each service to the services and
+// classes its definition names, e.g. in arguments, calls or a factory, and
+// from an alias, typically an interface, to its target. A definition with
+// a resource applies to every class file the resource matches.
+func readServices(root string, files []string, classToFile map[string]string, p *Project) {
+	type definition struct {
+		id, dir string
+		def     any
+	}
+	var defs []definition
+	aliases := make(map[string]string) // service id -> target id
+	classes := make(map[string]string) // service id -> class
+	for _, doc := range readYAML(root, &p.Warnings, "config/services.yaml", "config/services_*.yaml", "config/services/*.yaml") {
+		docMap, _ := doc.value.(map[string]any)
+		services, _ := docMap["services"].(map[string]any)
+		for _, id := range slices.Sorted(maps.Keys(services)) {
+			// _defaults and _instanceof apply to many services
+			if strings.HasPrefix(id, "_") {
+				continue
+			}
+			def := services[id]
+			switch d := def.(type) {
+			case string:
+				aliases[id] = serviceID(d)
+			case map[string]any:
+				if alias, ok := d["alias"].(string); ok {
+					aliases[id] = serviceID(alias)
+				}
+				if class, ok := d["class"].(string); ok {
+					classes[id] = class
+				}
+			}
+			defs = append(defs, definition{id: id, dir: doc.dir, def: def})
+		}
+	}
+
+	// classOf follows aliases to the class of a service; an id without a
+	// definition is an autowired class
+	classOf := func(id string) string {
+		for range 10 {
+			target, ok := aliases[id]
+			if !ok {
+				break
+			}
+			id = target
+		}
+		if class, ok := classes[id]; ok {
+			return class
+		}
+		return id
+	}
+
+	for _, d := range defs {
+		var names []string
+		collectStrings(d.def, &names)
+
+		var refs []Reference
+		seen := make(map[string]bool)
+		for _, name := range names {
+			class := classOf(serviceID(name))
+			if _, ok := classToFile[class]; ok && !seen[class] {
+				seen[class] = true
+				refs = append(refs, Reference{Name: class, RefType: RefService})
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		m, _ := d.def.(map[string]any)
+		resource, ok := m["resource"].(string)
+		if !ok {
+			// An alias is referenced under its own id, not its target's
+			own := d.id
+			if class, ok := classes[d.id]; ok {
+				own = class
+			}
+			if f, ok := classToFile[own]; ok {
+				p.Refs[f] = append(p.Refs[f], refs...)
+			}
+			continue
+		}
+		include := newResourceGlob(d.dir, resource)
+		var exclude resourceGlob
+		var excludes []string
+		collectStrings(m["exclude"], &excludes)
+		for _, e := range excludes {
+			exclude = append(exclude, newResourceGlob(d.dir, e)...)
+		}
+		for _, f := range files {
+			if strings.HasSuffix(f, ".php") && include.match(f) && !exclude.match(f) {
+				p.Refs[f] = append(p.Refs[f], refs...)
+			}
+		}
+	}
+}
+
+// serviceID strips the reference syntax from a service argument:
+// "@mailer" and "@?mailer" name the service "mailer".
+func serviceID(arg string) string {
+	arg = strings.TrimPrefix(arg, "@")
+	return strings.TrimPrefix(arg, "?")
+}
+
+// collectStrings appends the strings found in a parsed YAML value.
+func collectStrings(v any, out *[]string) {
+	switch v := v.(type) {
+	case string:
+		// @@ escapes a literal @ and @= starts an expression
+		if !strings.HasPrefix(v, "@@") && !strings.HasPrefix(v, "@=") {
+			*out = append(*out, v)
+		}
+	case []any:
+		for _, item := range v {
+			collectStrings(item, out)
+		}
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			if k != "resource" && k != "exclude" {
+				collectStrings(v[k], out)
+			}
+		}
+	}
+}
+
+// readRoutes maps route names to controller files. Routes come from the
+// controller entries of config/routes*.yaml and from the #[Route]
+// attributes and @Route annotations of the controllers those files load,
+// by default the ones in src/Controller/.
+func readRoutes(root string, files []string, classToFile map[string]string, routes map[string]string, warnings *[]string) {
+	var controllers resourceGlob
+	for _, doc := range readYAML(root, warnings, "config/routes.yaml", "config/routes/*.yaml") {
+		docMap, _ := doc.value.(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(docMap)) {
+			def, _ := docMap[name].(map[string]any)
+			if controller, ok := def["controller"].(string); ok {
+				class, _, _ := strings.Cut(controller, "::")
+				if f, ok := classToFile[class]; ok {
+					routes[name] = f
+				}
+			}
+			if t, _ := def["type"].(string); t != "attribute" && t != "annotation" {
+				continue
+			}
+			resource := def["resource"]
+			if m, ok := resource.(map[string]any); ok {
+				resource = m["path"]
+			}
+			if r, ok := resource.(string); ok {
+				controllers = append(controllers, newResourceGlob(doc.dir, r)...)
+			}
+		}
+	}
+	if controllers == nil {
+		controllers = newResourceGlob("", "src/Controller/")
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f, ".php") || !controllers.match(f) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f)))
+		if err != nil || !strings.Contains(string(data), "Route") {
+			continue
+		}
+		for _, name := range routeNames(string(data)) {
+			routes[name] = f
+		}
+	}
+}
+
+// routeNames returns the names of the routes a controller declares. The
+// name of a route on the class is the prefix of its methods' route names.
+func routeNames(src string) []string {
+	type route struct {
+		name string
+		pos  int
+	}
+	var declared []route
+	toks := lexer.Tokenize(src)
+	for _, t := range toks {
+		if t.Kind == lexer.DocComment {
+			for _, m := range routeAnnotation.FindAllStringSubmatch(t.Text, -1) {
+				declared = append(declared, route{m[1], t.Pos})
+			}
+		}
+	}
+
+	sig := lexer.Significant(toks)
+	classPos := -1
+	for i, t := range sig {
+		switch {
+		case t.Is("class") && classPos < 0 && i+1 < len(sig) && sig[i+1].Kind == lexer.Ident && (i == 0 || !sig[i-1].Is("::")):
+			classPos = t.Pos
+		case isRouteAttribute(t) && i+1 < len(sig) && sig[i+1].Is("("):
+			if name := namedArg(sig, i+1, "name"); name != "" {
+				declared = append(declared, route{name, t.Pos})
+			}
+		}
+	}
+	slices.SortFunc(declared, func(a, b route) int { return a.pos - b.pos })
+
+	var prefix string
+	var names []string
+	for _, r := range declared {
+		if r.pos < classPos {
+			prefix += r.name
+		} else {
+			names = append(names, prefix+r.name)
+		}
+	}
+	return names
+}
+
+func isRouteAttribute(t lexer.Token) bool {
+	return t.IsName() && (t.Text == "Route" || strings.HasSuffix(t.Text, "\\Route"))
+}
+
+// namedArg returns the string value of the named argument name: '...' in
+// the argument list opened at toks[open].
+func namedArg(toks []lexer.Token, open int, name string) string {
+	depth := 0
+	for j := open; j+2 < len(toks); j++ {
+		switch {
+		case toks[j].Is("("):
+			depth++
+		case toks[j].Is(")"):
+			depth--
+			if depth == 0 {
+				return ""
+			}
+		case depth == 1 && toks[j].Is(name) && toks[j+1].Is(":") && toks[j+2].Kind == lexer.String:
+			return toks[j+2].StringValue()
+		}
+	}
+	return ""
+}
+
+// resourceGlob matches relative paths against Symfony resource patterns
+// like "../src/{Entity,Kernel.php}", split into segments. A pattern
+// naming a directory matches everything below it.
+type resourceGlob [][]string
+
+// newResourceGlob resolves a resource pattern relative to dir.
+func newResourceGlob(dir, pattern string) resourceGlob {
+	var g resourceGlob
+	for _, p := range expandBraces(pattern) {
+		g = append(g, strings.Split(path.Clean(path.Join(dir, p)), "/"))
+	}
+	return g
+}
+
+func (g resourceGlob) match(relPath string) bool {
+	segs := strings.Split(relPath, "/")
+	for _, pattern := range g {
+		if matchPrefix(pattern, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPrefix reports whether the pattern matches the leading segments.
+// "**" matches any number of segments.
+func matchPrefix(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := range len(segs) + 1 {
+			if matchPrefix(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segs[0])
+	return ok && matchPrefix(pattern[1:], segs[1:])
+}
+
+// expandBraces expands {a,b} alternatives into separate patterns.
+func expandBraces(pattern string) []string {
+	open := strings.IndexByte(pattern, '{')
+	if open < 0 {
+		return []string{pattern}
+	}
+	end := strings.IndexByte(pattern[open:], '}')
+	if end < 0 {
+		return []string{pattern}
+	}
+	end += open
+	var out []string
+	for _, alt := range strings.Split(pattern[open+1:end], ",") {
+		out = append(out, expandBraces(pattern[:open]+alt+pattern[end+1:])...)
+	}
+	return out
+}
@@ -0,0 +1,203 @@
+package framework
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// wordPress covers plugins and themes, which are built from global
+// functions, hook callbacks and includes relative to the plugin or theme
+// directory rather than autoloaded classes.
+type wordPress struct{ Base }
+
+// RefCallback is the reference type of classes named in hook callbacks.
+const RefCallback = "callback"
+
+// Functions registering a callback in their second argument.
+var hookCalls = map[string]bool{
+	"add_action": true, "add_filter": true,
+	"remove_action": true, "remove_filter": true,
+	"add_shortcode":            true,
+	"register_activation_hook": true, "register_deactivation_hook": true,
+	"register_uninstall_hook": true,
+}
+
+// Language constructs written like function calls.
+var constructs = map[string]bool{
+	"if": true, "elseif": true, "while": true, "for": true, "foreach": true,
+	"switch": true, "match": true, "catch": true, "declare": true,
+	"array": true, "list": true, "isset": true, "unset": true, "empty": true,
+	"eval": true, "exit": true, "die": true, "echo": true, "print": true,
+	"return": true, "fn": true, "function": true, "use": true,
+	"include": true, "include_once": true, "require": true, "require_once": true,
+	"and": true, "or": true, "xor": true, "clone": true,
+}
+
+// Include idioms, e.g. plugin_dir_path(__FILE__) . 'includes/x.php',
+// TEMPLATEPATH . '/inc/x.php' and get_theme_file_path('inc/x.php').
+var (
+	wpDirCall  = regexp.MustCompile(`^(\w+)\s*\(\s*(.*?)\s*\)\s*\.\s*['"]([^'"$]+)['"]$`)
+	wpDirConst = regexp.MustCompile(`^(\w+)\s*\.\s*['"]([^'"$]+)['"]$`)
+	wpFileCall = regexp.MustCompile(`^(get_theme_file_path|get_parent_theme_file_path)\s*\(\s*['"]([^'"$]+)['"]\s*\)$`)
+)
+
+func (wordPress) Name() string  { return "wordpress" }
+func (wordPress) Label() string { return "WordPress" }
+
+// RefsAt finds calls of global functions and the callbacks of hooks, e.g.
+// add_action('init', 'myplugin_init') or add_filter('the_content',
+// array('MyPlugin_Filters', 'content')).
+func (wordPress) RefsAt(toks []lexer.Token, i int) []Reference {
+	t := toks[i]
+	if !t.IsName() || i+1 >= len(toks) || !toks[i+1].Is("(") {
+		return nil
+	}
+	if i > 0 {
+		prev := toks[i-1]
+		// Methods, declarations, instantiations and attributes
+		if prev.Is("->") || prev.Is("?->") || prev.Is("::") || prev.Is("function") || prev.Is("new") || prev.Is("#[") {
+			return nil
+		}
+		if prev.Is("&") && i > 1 && toks[i-2].Is("function") {
+			return nil
+		}
+	}
+
+	name := strings.TrimPrefix(t.Text, "\\")
+	var refs []Reference
+	if !constructs[strings.ToLower(name)] {
+		refs = append(refs, Reference{Name: name, RefType: RefFunction, Line: t.Line})
+	}
+	if hookCalls[name] {
+		if r, ok := hookCallback(toks, i+1); ok {
+			refs = append(refs, r)
+		}
+	}
+	return refs
+}
+
+// hookCallback returns the callback named in the second argument of the
+// call opened at toks[open]: a function name, "Class::method" or
+// array('Class', 'method'). Callbacks on objects name no class.
+func hookCallback(toks []lexer.Token, open int) (Reference, bool) {
+	at := func(j int) lexer.Token {
+		if j >= len(toks) {
+			return lexer.Token{Kind: lexer.Whitespace}
+		}
+		return toks[j]
+	}
+
+	// Skip the hook name
+	j := open + 1
+	for depth := 0; j < len(toks); j++ {
+		t := toks[j]
+		if depth == 0 && (t.Is(",") || t.Is(")")) {
+			break
+		}
+		if t.Is("(") || t.Is("[") {
+			depth++
+		} else if t.Is(")") || t.Is("]") {
+			depth--
+		}
+	}
+	if !at(j).Is(",") {
+		return Reference{}, false
+	}
+
+	arg := at(j + 1)
+	switch {
+	case arg.Kind == lexer.String:
+		callback := arg.StringValue()
+		if class, _, ok := strings.Cut(callback, "::"); ok {
+			// Strings hold fully-qualified names
+			return Reference{Name: "\\" + strings.TrimPrefix(class, "\\"), RefType: RefCallback, Line: arg.Line}, true
+		}
+		if callback != "" {
+			return Reference{Name: strings.TrimPrefix(callback, "\\"), RefType: RefHook, Line: arg.Line}, true
+		}
+	case arg.Is("array") && at(j+2).Is("(") && at(j+3).Kind == lexer.String:
+		class := at(j + 3)
+		return Reference{Name: "\\" + strings.TrimPrefix(class.StringValue(), "\\"), RefType: RefCallback, Line: class.Line}, true
+	case arg.Is("[") && at(j+2).Kind == lexer.String:
+		class := at(j + 2)
+		return Reference{Name: "\\" + strings.TrimPrefix(class.StringValue(), "\\"), RefType: RefCallback, Line: class.Line}, true
+	}
+	return Reference{}, false
+}
+
+// Project resolves function calls and hook callbacks through the index of
+// global functions. Most calls and many callbacks, like 'wpautop' or
+// '__return_false', go to PHP or WordPress itself, so only the ones that
+// are found count.
+func (wordPress) Project(idx Index) Project {
+	return Project{
+		Names: map[string]map[string]string{
+			RefFunction: idx.FuncToFile,
+			RefHook:     idx.FuncToFile,
+		},
+		Optional: map[string]bool{RefFunction: true, RefHook: true},
+	}
+}
+
+// IncludePath resolves includes relative to the plugin or theme directory.
+func (wordPress) IncludePath(expr, relPath string) string {
+	dir, rest, ok := includeBase(expr, relPath)
+	if !ok {
+		return ""
+	}
+	p := path.Join(dir, rest)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return ""
+	}
+	return p
+}
+
+// includeBase splits an include idiom into the directory it starts from
+// and the path below it.
+func includeBase(expr, relPath string) (dir, rest string, ok bool) {
+	if m := wpDirCall.FindStringSubmatch(expr); m != nil {
+		arg := strings.Join(strings.Fields(m[2]), "")
+		switch {
+		// plugin_dir_path() returns the directory of the path it's given
+		case m[1] == "plugin_dir_path" && arg == "__FILE__":
+			return path.Dir(relPath), m[3], true
+		case m[1] == "plugin_dir_path" && (arg == "__DIR__" || arg == "dirname(__FILE__)"):
+			return path.Dir(path.Dir(relPath)), m[3], true
+		case (m[1] == "get_template_directory" || m[1] == "get_stylesheet_directory") && arg == "":
+			return themeDir(relPath), m[3], true
+		}
+		return "", "", false
+	}
+	if m := wpDirConst.FindStringSubmatch(expr); m != nil {
+		switch m[1] {
+		case "TEMPLATEPATH", "STYLESHEETPATH":
+			return themeDir(relPath), m[2], true
+		case "ABSPATH":
+			return ".", m[2], true
+		case "WP_CONTENT_DIR":
+			return "wp-content", m[2], true
+		case "WP_PLUGIN_DIR":
+			return "wp-content/plugins", m[2], true
+		}
+		return "", "", false
+	}
+	if m := wpFileCall.FindStringSubmatch(expr); m != nil {
+		return themeDir(relPath), m[2], true
+	}
+	return "", "", false
+}
+
+// themeDir returns the theme directory of a file: the directory below
+// themes/, or the project root when a theme is scanned on its own.
+func themeDir(relPath string) string {
+	segs := strings.Split(relPath, "/")
+	for k := len(segs) - 3; k >= 0; k-- {
+		if segs[k] == "themes" {
+			return strings.Join(segs[:k+2], "/")
+		}
+	}
+	return "."
+}
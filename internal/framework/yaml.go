@@ -0,0 +1,608 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// yamlDoc is a parsed document of a configuration file.
+type yamlDoc struct {
+	dir   string // directory of the file, relative to the project root
+	value any
+}
+
+// readYAML parses the files matching the patterns, relative to root, in a
+// stable order. Files that can't be read are skipped; files using YAML
+// the parser doesn't support are skipped with a warning, so that they
+// aren't mistaken for files defining nothing.
+func readYAML(root string, warnings *[]string, patterns ...string) []yamlDoc {
+	var docs []yamlDoc
+	for _, pattern := range patterns {
+		dir := path.Dir(pattern)
+		matches, _ := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		for _, m := range matches {
+			data, err := os.ReadFile(m)
+			if err != nil {
+				continue
+			}
+			values, err := parseYAML(string(data))
+			if err != nil {
+				*warnings = append(*warnings, fmt.Sprintf("%s skipped: %v", path.Join(dir, filepath.Base(m)), err))
+				continue
+			}
+			for _, v := range values {
+				docs = append(docs, yamlDoc{dir: dir, value: v})
+			}
+		}
+	}
+	return docs
+}
+
+// yamlLine is a line of a YAML file without indentation and comment.
+type yamlLine struct {
+	num    int // 1-based, for errors
+	indent int
+	text   string
+	block  []string // raw lines of the block scalar the line starts
+}
+
+// yamlError reports YAML the parser doesn't support.
+type yamlError struct {
+	line int
+	msg  string
+}
+
+func (e *yamlError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// blockHeader matches the header of a literal (|) or folded (>) block
+// scalar with optional chomping and indentation indicators.
+var blockHeader = regexp.MustCompile(`^[|>]([+-][1-9]?|[1-9][+-]?)?$`)
+
+// parseYAML reads the subset of YAML used by configuration files: block
+// mappings and sequences, flow collections, quoted, plain and block
+// scalars, anchors, aliases and << merge keys, in one or more documents.
+// Tags are dropped. Mappings become map[string]any, sequences []any,
+// scalars strings and ~ or null nil. Anything else, like complex keys,
+// is an error rather than a guess.
+func parseYAML(src string) ([]any, error) {
+	docs, err := yamlDocuments(src)
+	if err != nil {
+		return nil, err
+	}
+	var values []any
+	for _, lines := range docs {
+		if len(lines) == 0 {
+			continue
+		}
+		p := &yamlParser{lines: lines, anchors: make(map[string]any)}
+		v := p.node(lines[0].indent)
+		if p.err == nil && p.i < len(lines) {
+			p.fail(lines[p.i].num, "unexpected indentation")
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// yamlDocuments splits src into documents of significant lines. Flow
+// collections spanning several lines are joined into one, and the lines
+// of block scalars are attached to the line starting them.
+func yamlDocuments(src string) ([][]yamlLine, error) {
+	var docs [][]yamlLine
+	var lines []yamlLine
+	open := 0
+	raw := strings.Split(src, "\n")
+	for n := 0; n < len(raw); n++ {
+		r := strings.TrimSuffix(raw[n], "\r")
+		if open == 0 {
+			switch {
+			case r == "---" || r == "...":
+				docs = append(docs, lines)
+				lines = nil
+				continue
+			case strings.HasPrefix(r, "--- "):
+				return nil, &yamlError{n + 1, "content after a document marker isn't supported"}
+			case strings.HasPrefix(r, "%"):
+				// Directives like %YAML 1.2
+				continue
+			}
+		}
+
+		r = strings.TrimRight(stripComment(r), " \t")
+		text := strings.TrimLeft(r, " ")
+		if text == "" {
+			continue
+		}
+		if text[0] == '\t' {
+			return nil, &yamlError{n + 1, "tabs can't indent YAML"}
+		}
+		if open > 0 {
+			lines[len(lines)-1].text += " " + text
+			open += flowDepth(text)
+			continue
+		}
+
+		l := yamlLine{num: n + 1, indent: len(r) - len(text), text: text}
+		if value, offset := entryValue(text); blockHeader.MatchString(value) {
+			// The block ends before the first line not indented deeper
+			// than its entry
+			for n+1 < len(raw) {
+				next := strings.TrimRight(strings.TrimSuffix(raw[n+1], "\r"), " \t")
+				t := strings.TrimLeft(next, " ")
+				if t != "" && len(next)-len(t) <= l.indent+offset {
+					break
+				}
+				l.block = append(l.block, next)
+				n++
+			}
+		}
+		lines = append(lines, l)
+		open += flowDepth(text)
+	}
+	if open > 0 {
+		return nil, &yamlError{lines[len(lines)-1].num, "unterminated flow collection"}
+	}
+	return append(docs, lines), nil
+}
+
+// entryValue returns the value a line gives to its mapping entry or
+// sequence item, without tag and anchor, and the offset of the entry in
+// the line: "- key: |" is an entry at offset 2.
+func entryValue(text string) (value string, offset int) {
+	if isSeqItem(text) {
+		rest := strings.TrimLeft(text[1:], " ")
+		if isEntry(rest) || isSeqItem(rest) {
+			value, offset = entryValue(rest)
+			return value, offset + len(text) - len(rest)
+		}
+		_, v := cutAnchor(stripTag(rest))
+		return v, 0
+	}
+	if _, value, ok := splitEntry(text); ok {
+		_, v := cutAnchor(stripTag(value))
+		return v, 0
+	}
+	return "", 0
+}
+
+// scanUnquoted calls fn for every byte of s outside quoted scalars. A
+// quote only starts a scalar at the beginning of a value, so "it's" stays
+// plain. fn returns false to stop.
+func scanUnquoted(s string, fn func(i int) bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '\'' || c == '"') && (i == 0 || strings.IndexByte(" [{,:-", s[i-1]) >= 0):
+			quote = c
+		default:
+			if !fn(i) {
+				return
+			}
+		}
+	}
+}
+
+// stripComment removes a # comment from a line.
+func stripComment(s string) string {
+	end := len(s)
+	scanUnquoted(s, func(i int) bool {
+		if s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			end = i
+			return false
+		}
+		return true
+	})
+	return s[:end]
+}
+
+// flowDepth returns how many flow collections s leaves open.
+func flowDepth(s string) int {
+	depth := 0
+	scanUnquoted(s, func(i int) bool {
+		switch s[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+		return true
+	})
+	return depth
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlParser parses the lines of one document.
+type yamlParser struct {
+	lines   []yamlLine
+	i       int
+	anchors map[string]any
+	err     error
+}
+
+// fail records the first error.
+func (p *yamlParser) fail(line int, format string, args ...any) {
+	if p.err == nil {
+		p.err = &yamlError{line, fmt.Sprintf(format, args...)}
+	}
+}
+
+// node parses the block starting at the current line if it is indented
+// at least by indent.
+func (p *yamlParser) node(indent int) any {
+	if p.i >= len(p.lines) || p.lines[p.i].indent < indent {
+		return nil
+	}
+	l := p.lines[p.i]
+	switch {
+	case isSeqItem(l.text):
+		return p.seq(l.indent)
+	case l.text == "?" || strings.HasPrefix(l.text, "? "):
+		p.fail(l.num, "complex mapping keys aren't supported")
+		return nil
+	case !isEntry(l.text):
+		// A scalar or flow collection on the lines following its key
+		s := l.text
+		for p.i++; p.i < len(p.lines) && p.lines[p.i].indent >= l.indent; p.i++ {
+			s += " " + p.lines[p.i].text
+		}
+		return p.scalarOrFlow(s, l.num)
+	}
+	return p.mapping(l.indent)
+}
+
+func (p *yamlParser) seq(indent int) []any {
+	var seq []any
+	for p.err == nil && p.i < len(p.lines) && p.lines[p.i].indent >= indent {
+		l := p.lines[p.i]
+		if l.indent > indent {
+			p.fail(l.num, "unexpected indentation")
+			break
+		}
+		if !isSeqItem(l.text) {
+			break
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if isEntry(rest) || isSeqItem(rest) {
+			// "- key: value" starts a mapping aligned after the dash,
+			// "- - item" a sequence
+			p.lines[p.i] = yamlLine{num: l.num, indent: indent + len(l.text) - len(rest), text: rest, block: l.block}
+			seq = append(seq, p.node(p.lines[p.i].indent))
+			continue
+		}
+		p.i++
+		seq = append(seq, p.value(rest, l, indent))
+	}
+	return seq
+}
+
+func (p *yamlParser) mapping(indent int) map[string]any {
+	m := make(map[string]any)
+	var merges []any
+	for p.err == nil && p.i < len(p.lines) && p.lines[p.i].indent >= indent {
+		l := p.lines[p.i]
+		if l.indent > indent {
+			p.fail(l.num, "unexpected indentation")
+			break
+		}
+		key, value, ok := splitEntry(l.text)
+		if !ok {
+			if isSeqItem(l.text) {
+				break
+			}
+			p.fail(l.num, "expected a mapping entry, found %q", l.text)
+			break
+		}
+		p.i++
+
+		var v any
+		if value == "" && p.i < len(p.lines) && p.lines[p.i].indent == indent && isSeqItem(p.lines[p.i].text) {
+			// Sequences may sit at the indentation of their key
+			v = p.seq(indent)
+		} else {
+			v = p.value(value, l, indent)
+		}
+		if key == "<<" && !strings.HasPrefix(l.text, "'") && !strings.HasPrefix(l.text, `"`) {
+			merges = append(merges, v)
+			continue
+		}
+		m[key] = v
+	}
+
+	// Keys of the entry win over merged ones
+	for _, merge := range merges {
+		sources, ok := merge.([]any)
+		if !ok {
+			sources = []any{merge}
+		}
+		for _, src := range sources {
+			sm, ok := src.(map[string]any)
+			if !ok {
+				continue
+			}
+			for k, v := range sm {
+				if _, ok := m[k]; !ok {
+					m[k] = v
+				}
+			}
+		}
+	}
+	return m
+}
+
+// value parses the value s that line l gives to an entry or item at
+// indent: a scalar, which may continue on deeper lines, a flow
+// collection, a block scalar, an alias or the block on the following
+// lines.
+func (p *yamlParser) value(s string, l yamlLine, indent int) any {
+	anchor, s := cutAnchor(stripTag(s))
+	s = stripTag(s)
+
+	var v any
+	switch {
+	case s == "":
+		v = p.node(indent + 1)
+	case blockHeader.MatchString(s):
+		v = blockScalar(s, l.block)
+	default:
+		// Multi-line plain and quoted scalars
+		for p.i < len(p.lines) && p.lines[p.i].indent > indent {
+			s += " " + p.lines[p.i].text
+			p.i++
+		}
+		v = p.scalarOrFlow(s, l.num)
+	}
+	if anchor != "" {
+		p.anchors[anchor] = v
+	}
+	return v
+}
+
+// scalarOrFlow parses an inline value.
+func (p *yamlParser) scalarOrFlow(s string, line int) any {
+	switch {
+	case s == "~" || s == "null":
+		return nil
+	case strings.HasPrefix(s, "*"):
+		return p.alias(s[1:], line)
+	case strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{"):
+		v, _ := p.flow(s, 0, line)
+		return v
+	}
+	v, _ := parseScalar(s, 0, "")
+	return v
+}
+
+func (p *yamlParser) alias(name string, line int) any {
+	v, ok := p.anchors[name]
+	if !ok {
+		p.fail(line, "unknown alias *%s", name)
+	}
+	return v
+}
+
+// cutAnchor splits an &anchor off a value.
+func cutAnchor(s string) (anchor, rest string) {
+	if !strings.HasPrefix(s, "&") {
+		return "", s
+	}
+	anchor, rest, _ = strings.Cut(s[1:], " ")
+	return anchor, strings.TrimSpace(rest)
+}
+
+// blockScalar returns the content of a block scalar: its lines without
+// their common indentation, joined by newlines (|) or folded into spaces
+// (>), and ending in one newline unless the header asks to strip (-) or
+// keep (+) trailing newlines.
+func blockScalar(header string, raw []string) string {
+	indent := 0
+	for _, r := range raw {
+		if t := strings.TrimLeft(r, " "); t != "" {
+			indent = len(r) - len(t)
+			break
+		}
+	}
+	lines := make([]string, len(raw))
+	for k, r := range raw {
+		if len(r) > indent {
+			lines[k] = r[indent:]
+		}
+	}
+
+	var b strings.Builder
+	for k, l := range lines {
+		if k > 0 {
+			prev := lines[k-1]
+			switch {
+			case header[0] == '|':
+				b.WriteByte('\n')
+			// A folded line break becomes a space, except around
+			// blank and more indented lines
+			case prev != "" && l != "" && prev[0] != ' ' && l[0] != ' ':
+				b.WriteByte(' ')
+			case prev != "" && l == "":
+			default:
+				b.WriteByte('\n')
+			}
+		}
+		b.WriteString(l)
+	}
+
+	text := b.String()
+	body := strings.TrimRight(text, "\n")
+	switch {
+	case strings.Contains(header, "-"):
+		return body
+	case strings.Contains(header, "+"):
+		return text + "\n"
+	case body == "":
+		return ""
+	}
+	return body + "\n"
+}
+
+func isEntry(s string) bool {
+	_, _, ok := splitEntry(s)
+	return ok
+}
+
+// splitEntry splits a mapping entry "key: value". ok is false if s isn't
+// one.
+func splitEntry(s string) (key, value string, ok bool) {
+	if s == "" || s[0] == '[' || s[0] == '{' {
+		return "", "", false
+	}
+	start := 0
+	if s[0] == '\'' || s[0] == '"' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", "", false
+		}
+		start = end + 2
+	}
+	for j := start; j < len(s); j++ {
+		if s[j] == ':' && (j+1 == len(s) || s[j+1] == ' ') {
+			key, _ = parseScalar(strings.TrimSpace(s[:j]), 0, "")
+			return key, strings.TrimSpace(s[j+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripTag removes a tag like !service or !tagged_iterator from a value.
+func stripTag(s string) string {
+	if !strings.HasPrefix(s, "!") {
+		return s
+	}
+	_, rest, _ := strings.Cut(s, " ")
+	return strings.TrimSpace(rest)
+}
+
+// flow parses the flow value at s[pos:] and returns the position after
+// it.
+func (p *yamlParser) flow(s string, pos, line int) (any, int) {
+	pos = skipSpace(s, pos)
+	var anchor string
+	for pos < len(s) && (s[pos] == '!' || s[pos] == '&') {
+		start := pos
+		for pos < len(s) && s[pos] != ' ' {
+			pos++
+		}
+		if s[start] == '&' {
+			anchor = s[start+1 : pos]
+		}
+		pos = skipSpace(s, pos)
+	}
+	v, pos := p.flowValue(s, pos, line)
+	if anchor != "" {
+		p.anchors[anchor] = v
+	}
+	return v, pos
+}
+
+func (p *yamlParser) flowValue(s string, pos, line int) (any, int) {
+	if pos >= len(s) {
+		return nil, pos
+	}
+	switch s[pos] {
+	case '*':
+		end := pos + 1
+		for end < len(s) && strings.IndexByte(" ,]}", s[end]) < 0 {
+			end++
+		}
+		return p.alias(s[pos+1:end], line), end
+	case '[':
+		var seq []any
+		for pos++; ; {
+			pos = skipSpace(s, pos)
+			switch {
+			case pos >= len(s):
+				return seq, pos
+			case s[pos] == ']':
+				return seq, pos + 1
+			case s[pos] == ',':
+				pos++
+			default:
+				var v any
+				v, pos = p.flow(s, pos, line)
+				seq = append(seq, v)
+			}
+		}
+	case '{':
+		m := make(map[string]any)
+		for pos++; ; {
+			pos = skipSpace(s, pos)
+			switch {
+			case pos >= len(s):
+				return m, pos
+			case s[pos] == '}':
+				return m, pos + 1
+			case s[pos] == ',':
+				pos++
+			default:
+				var key string
+				key, pos = parseScalar(s, pos, ":,}")
+				pos = skipSpace(s, pos)
+				var v any
+				if pos < len(s) && s[pos] == ':' {
+					v, pos = p.flow(s, pos+1, line)
+				}
+				m[key] = v
+			}
+		}
+	}
+	return parseScalar(s, pos, ",]}")
+}
+
+// parseScalar parses the quoted or plain scalar at s[pos:]. A plain
+// scalar ends before one of the stop bytes.
+func parseScalar(s string, pos int, stops string) (string, int) {
+	if pos < len(s) && (s[pos] == '\'' || s[pos] == '"') {
+		q := s[pos]
+		var b strings.Builder
+		for j := pos + 1; j < len(s); j++ {
+			c := s[j]
+			switch {
+			case q == '\'' && c == '\'' && j+1 < len(s) && s[j+1] == '\'':
+				b.WriteByte('\'')
+				j++
+			case q == '"' && c == '\\' && j+1 < len(s):
+				b.WriteByte(s[j+1])
+				j++
+			case c == q:
+				return b.String(), j + 1
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return b.String(), len(s)
+	}
+	end := pos
+	for end < len(s) && strings.IndexByte(stops, s[end]) < 0 {
+		end++
+	}
+	return strings.TrimSpace(s[pos:end]), end
+}
+
+func skipSpace(s string, pos int) int {
+	for pos < len(s) && s[pos] == ' ' {
+		pos++
+	}
+	return pos
+}
@@ -0,0 +1,418 @@
+package framework
+
+import (
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// yii2 covers the basic and advanced application templates. Classes live
+// in the namespace of their directory, app\ for the project root and
+// common\, frontend\ and so on for the applications of the advanced
+// template. Files are named through path aliases like @app/views and
+// application components are configured in config/*.php.
+type yii2 struct{ Base }
+
+// Application directories of the advanced template, named like their namespace.
+var yiiAppDirs = map[string]bool{"common": true, "frontend": true, "backend": true, "console": true, "api": true}
+
+var (
+	// yiiAlias matches strings starting with a path alias.
+	yiiAlias = regexp.MustCompile(`^@\w+(/|$)`)
+	// yiiClassName matches namespaced class names.
+	yiiClassName = regexp.MustCompile(`^[A-Za-z_]\w*(\\[A-Za-z_]\w*)+$`)
+)
+
+func (yii2) Name() string  { return "yii2" }
+func (yii2) Label() string { return "Yii2" }
+
+// ClassFromPath derives class name from the path of a class file, which
+// is named in PascalCase unlike views and config files.
+// e.g. "models/User.php" -> "app\models\User" and
+// "common/models/User.php" -> "common\models\User"
+func (yii2) ClassFromPath(relPath string, _ []PrefixMapping) string {
+	p := strings.TrimSuffix(relPath, ".php")
+	if base := path.Base(p); base[0] < 'A' || base[0] > 'Z' {
+		return ""
+	}
+	if first, _, _ := strings.Cut(p, "/"); !yiiAppDirs[first] {
+		p = "app/" + p
+	}
+	return strings.ReplaceAll(p, "/", "\\")
+}
+
+// RefsAt finds application components, Yii::$app->mailer or
+// Yii::$app->get('mailer'), path aliases like '@app/views/site/index' and
+// class names in config arrays, 'class' => 'app\components\Mailer' or
+// 'identityClass' => 'app\models\User'.
+func (y yii2) RefsAt(toks []lexer.Token, i int) []Reference {
+	at := func(j int) lexer.Token {
+		if j >= len(toks) {
+			return lexer.Token{Kind: lexer.Whitespace}
+		}
+		return toks[j]
+	}
+
+	t := toks[i]
+	switch {
+	case (t.Text == "Yii" || t.Text == "\\Yii") && at(i+1).Is("::") && at(i+2).Text == "$app" && at(i+3).Is("->"):
+		c := at(i + 4)
+		switch {
+		case c.Is("get") && at(i+5).Is("(") && at(i+6).Kind == lexer.String:
+			return []Reference{{Name: at(i + 6).StringValue(), RefType: RefComponent, Line: c.Line}}
+		case c.Kind == lexer.Ident && !at(i+5).Is("("):
+			return []Reference{{Name: c.Text, RefType: RefComponent, Line: c.Line}}
+		}
+
+	case t.Kind == lexer.String:
+		v := t.StringValue()
+		if yiiAlias.MatchString(v) {
+			return []Reference{{Name: v, RefType: RefAlias, Line: t.Line}}
+		}
+		if (v == "class" || strings.HasSuffix(v, "Class")) && at(i+1).Is("=>") && at(i+2).Kind == lexer.String {
+			name := strings.TrimPrefix(at(i+2).StringValue(), "\\")
+			if yiiClassName.MatchString(name) && !hasPrefix(name, y.CorePrefixes()) {
+				return []Reference{{Name: name, RefType: RefClassName, Line: at(i + 2).Line}}
+			}
+		}
+	}
+	return nil
+}
+
+func (yii2) CorePrefixes() []string {
+	return []string{"Yii", "yii\\"}
+}
+
+// Project resolves aliases to the files below their directories,
+// components to the files of their configured classes and class names in
+// config arrays through the class index. Aliases and components that
+// can't be found, like @web or Yii::$app->request, mostly belong to Yii
+// itself and aren't reported.
+func (yii2) Project(idx Index) Project {
+	cfg := readYiiConfig(idx.Root, idx.Files)
+
+	aliases := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(cfg.aliases)) {
+		dir, ok := cfg.expand(name)
+		if !ok {
+			continue
+		}
+		for _, f := range idx.Files {
+			if f == dir {
+				aliases[name] = f
+			}
+			if rest, ok := cutDir(f, dir); ok {
+				aliases[name+"/"+rest] = f
+				aliases[name+"/"+strings.TrimSuffix(rest, ".php")] = f
+			}
+		}
+	}
+
+	components := make(map[string]string)
+	for id, class := range cfg.components {
+		if f, ok := idx.ClassToFile[class]; ok {
+			components[id] = f
+		}
+	}
+
+	return Project{
+		Names: map[string]map[string]string{
+			RefAlias:     aliases,
+			RefComponent: components,
+			RefClassName: idx.ClassToFile,
+		},
+		Optional: map[string]bool{RefAlias: true, RefComponent: true},
+	}
+}
+
+// yiiConfig is what the config files of a project define.
+type yiiConfig struct {
+	aliases    map[string]string // "@common" -> "common"; may start with another alias
+	components map[string]string // component id -> class
+}
+
+// readYiiConfig reads the config/*.php files of the project root and of
+// the applications of the advanced template. They define aliases in an
+// 'aliases' array or with Yii::setAlias(), usually in bootstrap.php, and
+// components in a 'components' array. @app defaults to the project root,
+// or to the basePath if exactly one application sets one.
+func readYiiConfig(root string, files []string) yiiConfig {
+	c := yiiConfig{aliases: make(map[string]string), components: make(map[string]string)}
+	var basePaths []string
+	for _, f := range files {
+		if path.Base(path.Dir(f)) != "config" || strings.Count(f, "/") > 2 || !strings.HasSuffix(f, ".php") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f)))
+		if err != nil {
+			continue
+		}
+		basePaths = append(basePaths, c.read(lexer.Significant(lexer.Tokenize(string(data))), f)...)
+	}
+	if _, ok := c.aliases["@app"]; !ok {
+		c.aliases["@app"] = "."
+		if len(basePaths) == 1 {
+			c.aliases["@app"] = basePaths[0]
+		}
+	}
+	return c
+}
+
+// read adds the aliases and components of a config file and returns the
+// basePaths it sets.
+func (c *yiiConfig) read(toks []lexer.Token, file string) []string {
+	imports := useImports(toks)
+	var basePaths []string
+	for i := 0; i+2 < len(toks); i++ {
+		t := toks[i]
+		switch {
+		case t.Is("setAlias") && toks[i+1].Is("(") && toks[i+2].Kind == lexer.String && i+3 < len(toks) && toks[i+3].Is(","):
+			if p, ok := evalPath(toks[i+4:exprEnd(toks, i+4)], file); ok {
+				c.aliases[toks[i+2].StringValue()] = p
+			}
+
+		case t.Kind == lexer.String && toks[i+1].Is("=>"):
+			switch t.StringValue() {
+			case "aliases":
+				for _, e := range arrayEntries(toks, i+2) {
+					if p, ok := evalPath(e.value, file); ok && e.key.Kind == lexer.String {
+						c.aliases[e.key.StringValue()] = p
+					}
+				}
+			case "basePath":
+				if p, ok := evalPath(toks[i+2:exprEnd(toks, i+2)], file); ok {
+					basePaths = append(basePaths, p)
+				}
+			case "components":
+				for _, e := range arrayEntries(toks, i+2) {
+					if e.key.Kind != lexer.String {
+						continue
+					}
+					// 'cache' => 'app\components\Cache' or 'mailer' => ['class' => ...]
+					value := e.value
+					if len(value) > 0 && (value[0].Is("[") || value[0].Is("array")) {
+						value = nil
+						for _, prop := range arrayEntries(e.value, 0) {
+							if prop.key.Kind == lexer.String && prop.key.StringValue() == "class" {
+								value = prop.value
+							}
+						}
+					}
+					if class := classValue(value, imports); class != "" {
+						c.components[e.key.StringValue()] = class
+					}
+				}
+			}
+		}
+	}
+	return basePaths
+}
+
+// expand resolves an alias or a path starting with one to a path relative
+// to the project root, following aliases defined by others.
+func (c yiiConfig) expand(p string) (string, bool) {
+	for range 10 {
+		if !strings.HasPrefix(p, "@") {
+			return p, true
+		}
+		name, rest, _ := strings.Cut(p, "/")
+		dir, ok := c.aliases[name]
+		if !ok {
+			return "", false
+		}
+		p = path.Join(dir, rest)
+	}
+	return "", false
+}
+
+// entry is a key => value pair of a PHP array literal.
+type entry struct {
+	key   lexer.Token
+	value []lexer.Token
+}
+
+// arrayEntries returns the keyed entries of the array literal starting at
+// toks[i] with "[" or "array(".
+func arrayEntries(toks []lexer.Token, i int) []entry {
+	if i < len(toks) && toks[i].Is("array") {
+		i++
+	}
+	if i >= len(toks) || !(toks[i].Is("[") || toks[i].Is("(")) {
+		return nil
+	}
+	var entries []entry
+	depth := 0
+	start := i + 1
+	for j := i; j < len(toks); j++ {
+		t := toks[j]
+		if t.Is("(") || t.Is("[") || t.Is("{") {
+			depth++
+		} else if t.Is(")") || t.Is("]") || t.Is("}") {
+			depth--
+		}
+		if depth == 0 || (depth == 1 && t.Is(",")) {
+			if j-start >= 3 && toks[start+1].Is("=>") {
+				entries = append(entries, entry{key: toks[start], value: toks[start+2 : j]})
+			}
+			start = j + 1
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// exprEnd returns the index of the token ending the expression starting
+// at toks[start]: a comma or closing bracket at nesting depth 0.
+func exprEnd(toks []lexer.Token, start int) int {
+	depth := 0
+	for j := start; j < len(toks); j++ {
+		t := toks[j]
+		switch {
+		case t.Is("(") || t.Is("[") || t.Is("{"):
+			depth++
+		case t.Is(")") || t.Is("]") || t.Is("}"):
+			if depth == 0 {
+				return j
+			}
+			depth--
+		case depth == 0 && (t.Is(",") || t.Is(";")):
+			return j
+		}
+	}
+	return len(toks)
+}
+
+// evalPath evaluates a path expression of the config file at file to a
+// path relative to the project root. It understands concatenations of
+// __DIR__, __FILE__, dirname() and string literals; the result may start
+// with an alias.
+func evalPath(toks []lexer.Token, file string) (string, bool) {
+	var b strings.Builder
+	for first := true; len(toks) > 0; first = false {
+		if !first {
+			if !toks[0].Is(".") {
+				return "", false
+			}
+			toks = toks[1:]
+		}
+		if len(toks) == 0 {
+			return "", false
+		}
+
+		t := toks[0]
+		n := 1
+		switch {
+		case t.Is("__DIR__"):
+			b.WriteString(path.Dir(file))
+		case t.Is("__FILE__"):
+			b.WriteString(file)
+		case t.Is("dirname") && len(toks) > 1 && toks[1].Is("("):
+			end := exprEnd(toks, 2)
+			inner := toks[2:end]
+			levels := 1
+			// dirname(__DIR__, 2)
+			if end+2 < len(toks) && toks[end].Is(",") && toks[end+1].Kind == lexer.Number && toks[end+2].Is(")") {
+				levels, _ = strconv.Atoi(toks[end+1].Text)
+				end += 2
+			}
+			if end >= len(toks) || !toks[end].Is(")") {
+				return "", false
+			}
+			p, ok := evalPath(inner, file)
+			if !ok {
+				return "", false
+			}
+			for range levels {
+				p = path.Dir(p)
+			}
+			b.WriteString(p)
+			n = end + 1
+		case t.Kind == lexer.String:
+			s := t.StringValue()
+			// Only aliases are known to be relative to the project
+			if first && !strings.HasPrefix(s, "@") {
+				return "", false
+			}
+			b.WriteString(s)
+		default:
+			return "", false
+		}
+		toks = toks[n:]
+	}
+
+	p := path.Clean(b.String())
+	if p == ".." || strings.HasPrefix(p, "../") || strings.HasPrefix(p, "/") {
+		return "", false
+	}
+	return p, true
+}
+
+// classValue returns the class named by a config value, a string or
+// Name::class, or "".
+func classValue(value []lexer.Token, imports map[string]string) string {
+	switch {
+	case len(value) == 1 && value[0].Kind == lexer.String:
+		name := strings.TrimPrefix(value[0].StringValue(), "\\")
+		if yiiClassName.MatchString(name) {
+			return name
+		}
+	case len(value) == 3 && value[0].IsName() && value[1].Is("::") && value[2].Is("class"):
+		name := value[0].Text
+		if value[0].Kind == lexer.NameFullyQualified {
+			return strings.TrimPrefix(name, "\\")
+		}
+		first, rest, qualified := strings.Cut(name, "\\")
+		if target, ok := imports[strings.ToLower(first)]; ok {
+			if qualified {
+				return target + "\\" + rest
+			}
+			return target
+		}
+		return name
+	}
+	return ""
+}
+
+// useImports returns the classes a file imports by their lower-case alias.
+func useImports(toks []lexer.Token) map[string]string {
+	imports := make(map[string]string)
+	for i := 0; i+2 < len(toks); i++ {
+		if !toks[i].Is("use") || !toks[i+1].IsName() {
+			continue
+		}
+		name := strings.TrimPrefix(toks[i+1].Text, "\\")
+		alias := path.Base(strings.ReplaceAll(name, "\\", "/"))
+		if toks[i+2].Is("as") && i+3 < len(toks) {
+			alias = toks[i+3].Text
+		}
+		imports[strings.ToLower(alias)] = name
+	}
+	return imports
+}
+
+func hasPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// cutDir returns relPath relative to dir if it lies inside it; "." is the
+// project root.
+func cutDir(relPath, dir string) (string, bool) {
+	if dir == "." {
+		return relPath, true
+	}
+	return strings.CutPrefix(relPath, dir+"/")
+}
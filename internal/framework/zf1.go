@@ -0,0 +1,81 @@
+package framework
+
+import (
+	"strings"
+)
+
+// zf1 is Zend Framework 1: classes under application/ are named after
+// their directory through prefix mappings, e.g. Model_Car_CarrierCust in
+// application/models/Car/CarrierCust.php.
+type zf1 struct{ Base }
+
+// DefaultZF1Mappings returns the default ZF1 prefix→directory mappings.
+func DefaultZF1Mappings() []PrefixMapping {
+	return []PrefixMapping{
+		{Prefix: "Parent_", Dir: "parents/"},
+		{Prefix: "DbTable_", Dir: "dbs/"},
+		{Prefix: "Service_", Dir: "services/"},
+		{Prefix: "Model_", Dir: "models/"},
+		{Prefix: "Form_", Dir: "forms/"},
+	}
+}
+
+func (zf1) Name() string  { return "zf1" }
+func (zf1) Label() string { return "ZF1" }
+
+func (zf1) Extensions() []string {
+	return []string{".php", ".phtml", ".inc"}
+}
+
+// ClassFromPath derives class name from ZF1 path conventions.
+// e.g. "application/models/Car/CarrierCust.php" -> "Model_Car_CarrierCust"
+func (zf1) ClassFromPath(relPath string, mappings []PrefixMapping) string {
+	// Normalize path
+	p := strings.TrimSuffix(relPath, ".php")
+
+	// Try to strip "application/" prefix
+	appPath := p
+	if strings.HasPrefix(p, "application/") {
+		appPath = strings.TrimPrefix(p, "application/")
+	} else {
+		// Not under application/, skip ZF1 mapping
+		return ""
+	}
+
+	// Check each mapping (order matters - longer/more specific prefixes first)
+	for _, m := range mappings {
+		dir := strings.TrimSuffix(m.Dir, "/")
+		if strings.HasPrefix(appPath, dir+"/") {
+			rest := strings.TrimPrefix(appPath, dir+"/")
+			// Convert path separators to underscores
+			className := m.Prefix + strings.ReplaceAll(rest, "/", "_")
+			return className
+		}
+	}
+
+	// Controllers: application/controllers/V3/CustomersController.php
+	if strings.HasPrefix(appPath, "controllers/") {
+		return ""
+	}
+
+	return ""
+}
+
+// CandidatePaths resolves short names: "CarrierCust" may be
+// Model_CarrierCust in application/models/CarrierCust.php, or any other
+// mapped prefix.
+func (zf1) CandidatePaths(className string, mappings []PrefixMapping) []string {
+	if strings.Contains(className, "\\") {
+		return nil
+	}
+	rest := strings.ReplaceAll(className, "_", "/") + ".php"
+	paths := make([]string, 0, len(mappings))
+	for _, m := range mappings {
+		paths = append(paths, "application/"+strings.TrimSuffix(m.Dir, "/")+"/"+rest)
+	}
+	return paths
+}
+
+func (zf1) CorePrefixes() []string {
+	return []string{"Zend_", "ZendX_"}
+}
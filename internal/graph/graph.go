@@ -0,0 +1,213 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"php-dep-extractor/internal/parser"
+)
+
+// Format selects the output syntax of Write.
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatMermaid Format = "mermaid"
+	FormatGraphML Format = "graphml"
+)
+
+// ParseFormat validates a format name. "gv" and "mmd" are accepted as aliases.
+func ParseFormat(s string) (Format, bool) {
+	switch strings.ToLower(s) {
+	case "dot", "gv":
+		return FormatDOT, true
+	case "mermaid", "mmd":
+		return FormatMermaid, true
+	case "graphml":
+		return FormatGraphML, true
+	}
+	return "", false
+}
+
+// ContentType returns the MIME type used when serving the format.
+func (f Format) ContentType() string {
+	if f == FormatGraphML {
+		return "application/graphml+xml; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Node is a file in the dependency graph.
+type Node struct {
+	ID       string
+	Path     string
+	Depth    int // 0 for selected files
+	Selected bool
+}
+
+// Graph is the node and edge list of an analysis.
+type Graph struct {
+	Nodes []Node
+	Edges []parser.Edge
+	ids   map[string]string // path -> node ID
+}
+
+// Build creates a graph from the selected files and an analysis result.
+// Nodes are numbered in discovery order so output is stable.
+func Build(selectedFiles []string, result *parser.DependencyResult) *Graph {
+	g := &Graph{ids: make(map[string]string)}
+	addNode := func(path string, depth int) {
+		if _, ok := g.ids[path]; ok {
+			return
+		}
+		id := fmt.Sprintf("n%d", len(g.Nodes))
+		g.ids[path] = id
+		g.Nodes = append(g.Nodes, Node{ID: id, Path: path, Depth: depth, Selected: depth == 0})
+	}
+
+	for _, f := range selectedFiles {
+		addNode(f, 0)
+	}
+	for _, d := range result.Dependencies {
+		addNode(d.FilePath, d.Depth)
+	}
+	// Edge endpoints are always selected files or dependencies
+	g.Edges = result.Edges
+	return g
+}
+
+// Write renders the graph in the given format.
+func Write(w io.Writer, g *Graph, format Format) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case FormatDOT:
+		writeDOT(bw, g)
+	case FormatMermaid:
+		writeMermaid(bw, g)
+	case FormatGraphML:
+		if err := writeGraphML(bw, g); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown graph format %q", format)
+	}
+	return bw.Flush()
+}
+
+func writeDOT(w *bufio.Writer, g *Graph) {
+	w.WriteString("digraph dependencies {\n")
+	w.WriteString("  rankdir=LR;\n")
+	w.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, n := range g.Nodes {
+		attrs := ""
+		if n.Selected {
+			attrs = ", style=filled, fillcolor=\"#cfe2ff\""
+		}
+		fmt.Fprintf(w, "  %s [label=%s%s];\n", n.ID, dotQuote(n.Path), attrs)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %s -> %s [label=%s];\n", g.ids[e.From], g.ids[e.To], dotQuote(e.RefType))
+	}
+	w.WriteString("}\n")
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func writeMermaid(w *bufio.Writer, g *Graph) {
+	w.WriteString("graph LR\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", n.ID, mermaidEscape(n.Path))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %s -->|%s| %s\n", g.ids[e.From], mermaidEscape(e.RefType), g.ids[e.To])
+	}
+	var selected []string
+	for _, n := range g.Nodes {
+		if n.Selected {
+			selected = append(selected, n.ID)
+		}
+	}
+	if len(selected) > 0 {
+		w.WriteString("  classDef selected fill:#cfe2ff,stroke:#0d6efd\n")
+		fmt.Fprintf(w, "  class %s selected\n", strings.Join(selected, ","))
+	}
+}
+
+// mermaidEscape replaces characters that end a Mermaid label with entity codes.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "|", "#124;").Replace(s)
+}
+
+func writeGraphML(w *bufio.Writer, g *Graph) error {
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	type node struct {
+		ID   string `xml:"id,attr"`
+		Data []data `xml:"data"`
+	}
+	type edge struct {
+		Source string `xml:"source,attr"`
+		Target string `xml:"target,attr"`
+		Data   []data `xml:"data"`
+	}
+	type key struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+	type graphElem struct {
+		EdgeDefault string `xml:"edgedefault,attr"`
+		Nodes       []node `xml:"node"`
+		Edges       []edge `xml:"edge"`
+	}
+	type graphML struct {
+		XMLName xml.Name  `xml:"graphml"`
+		XMLNS   string    `xml:"xmlns,attr"`
+		Keys    []key     `xml:"key"`
+		Graph   graphElem `xml:"graph"`
+	}
+
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []key{
+			{ID: "path", For: "node", Name: "path", Type: "string"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+			{ID: "selected", For: "node", Name: "selected", Type: "boolean"},
+			{ID: "refType", For: "edge", Name: "refType", Type: "string"},
+			{ID: "className", For: "edge", Name: "className", Type: "string"},
+			{ID: "line", For: "edge", Name: "line", Type: "int"},
+		},
+		Graph: graphElem{EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node{ID: n.ID, Data: []data{
+			{Key: "path", Value: n.Path},
+			{Key: "depth", Value: fmt.Sprint(n.Depth)},
+			{Key: "selected", Value: fmt.Sprint(n.Selected)},
+		}})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, edge{Source: g.ids[e.From], Target: g.ids[e.To], Data: []data{
+			{Key: "refType", Value: e.RefType},
+			{Key: "className", Value: e.ClassName},
+			{Key: "line", Value: fmt.Sprint(e.Line)},
+		}})
+	}
+
+	w.WriteString(xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	w.WriteString("\n")
+	return nil
+}
@@ -0,0 +1,393 @@
+package lexer
+
+import (
+	"strings"
+)
+
+// Kind identifies the type of a token. The names follow PHP's own
+// token_get_all() constants where a direct equivalent exists.
+type Kind int
+
+const (
+	InlineHTML         Kind = iota // T_INLINE_HTML: text outside <?php ... ?>
+	OpenTag                        // T_OPEN_TAG / T_OPEN_TAG_WITH_ECHO
+	CloseTag                       // T_CLOSE_TAG
+	Whitespace                     // T_WHITESPACE
+	Comment                        // T_COMMENT: //, # and /* */ comments
+	DocComment                     // T_DOC_COMMENT: /** */ comments
+	Variable                       // T_VARIABLE: $name
+	Ident                          // T_STRING: identifiers and keywords
+	NameQualified                  // T_NAME_QUALIFIED: Foo\Bar
+	NameFullyQualified             // T_NAME_FULLY_QUALIFIED: \Foo\Bar
+	NameRelative                   // T_NAME_RELATIVE: namespace\Foo
+	String                         // T_CONSTANT_ENCAPSED_STRING and interpolated strings
+	Heredoc                        // heredoc and nowdoc literals including delimiters
+	Number                         // T_LNUMBER / T_DNUMBER
+	Punct                          // operators and punctuation
+)
+
+func (k Kind) String() string {
+	switch k {
+	case InlineHTML:
+		return "T_INLINE_HTML"
+	case OpenTag:
+		return "T_OPEN_TAG"
+	case CloseTag:
+		return "T_CLOSE_TAG"
+	case Whitespace:
+		return "T_WHITESPACE"
+	case Comment:
+		return "T_COMMENT"
+	case DocComment:
+		return "T_DOC_COMMENT"
+	case Variable:
+		return "T_VARIABLE"
+	case Ident:
+		return "T_STRING"
+	case NameQualified:
+		return "T_NAME_QUALIFIED"
+	case NameFullyQualified:
+		return "T_NAME_FULLY_QUALIFIED"
+	case NameRelative:
+		return "T_NAME_RELATIVE"
+	case String:
+		return "T_CONSTANT_ENCAPSED_STRING"
+	case Heredoc:
+		return "T_HEREDOC"
+	case Number:
+		return "T_NUMBER"
+	case Punct:
+		return "T_PUNCT"
+	}
+	return "T_UNKNOWN"
+}
+
+// Token is a single lexical element of a PHP source file.
+type Token struct {
+	Kind Kind
+	Text string // exact source text
+	Line int    // 1-based line of the first character
+	Pos  int    // byte offset of the first character
+}
+
+// IsName reports whether the token is an identifier or a namespaced name.
+func (t Token) IsName() bool {
+	switch t.Kind {
+	case Ident, NameQualified, NameFullyQualified, NameRelative:
+		return true
+	}
+	return false
+}
+
+// Is reports whether the token is the given keyword (case-insensitive) or punctuation.
+func (t Token) Is(text string) bool {
+	switch t.Kind {
+	case Ident:
+		return strings.EqualFold(t.Text, text)
+	case Punct:
+		return t.Text == text
+	}
+	return false
+}
+
+// StringValue returns the contents of a quoted string literal with escapes
+// processed. Interpolated variables in double-quoted strings are left as-is.
+func (t Token) StringValue() string {
+	if t.Kind != String || len(t.Text) < 2 {
+		return ""
+	}
+	quote := t.Text[0]
+	body := t.Text[1 : len(t.Text)-1]
+	if quote == '\'' {
+		body = strings.ReplaceAll(body, `\\`, "\x00")
+		body = strings.ReplaceAll(body, `\'`, "'")
+		return strings.ReplaceAll(body, "\x00", `\`)
+	}
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\$`, `$`, `\n`, "\n", `\t`, "\t").Replace(body)
+}
+
+// Multi-character operators, longest first so the lexer can match greedily.
+var operators = []string{
+	"<=>", "===", "!==", "**=", "...", "<<=", ">>=", "??=", "?->",
+	"::", "->", "=>", "==", "!=", "<>", "<=", ">=", "&&", "||", "??",
+	"++", "--", "+=", "-=", "*=", "/=", ".=", "%=", "&=", "|=", "^=",
+	"<<", ">>", "**", "#[",
+}
+
+// Tokenize splits PHP source into tokens. It never fails: malformed input
+// such as an unterminated string simply extends the token to end of file.
+func Tokenize(src string) []Token {
+	l := &lexer{src: src, line: 1}
+	l.run()
+	return l.tokens
+}
+
+// Significant returns the tokens with whitespace, comments and inline HTML removed.
+func Significant(tokens []Token) []Token {
+	out := make([]Token, 0, len(tokens))
+	for _, t := range tokens {
+		switch t.Kind {
+		case Whitespace, Comment, DocComment, InlineHTML, OpenTag:
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+type lexer struct {
+	src    string
+	pos    int
+	line   int
+	tokens []Token
+}
+
+func (l *lexer) emit(kind Kind, end int) {
+	text := l.src[l.pos:end]
+	l.tokens = append(l.tokens, Token{Kind: kind, Text: text, Line: l.line, Pos: l.pos})
+	l.line += strings.Count(text, "\n")
+	l.pos = end
+}
+
+func (l *lexer) run() {
+	for l.pos < len(l.src) {
+		l.lexHTML()
+		l.lexPHP()
+	}
+}
+
+// lexHTML consumes inline HTML up to and including the next open tag.
+func (l *lexer) lexHTML() {
+	rest := l.src[l.pos:]
+	idx := strings.Index(rest, "<?")
+	if idx < 0 {
+		l.emit(InlineHTML, len(l.src))
+		return
+	}
+	if idx > 0 {
+		l.emit(InlineHTML, l.pos+idx)
+		rest = l.src[l.pos:]
+	}
+
+	tagLen := 2
+	switch {
+	case len(rest) >= 5 && strings.EqualFold(rest[:5], "<?php"):
+		tagLen = 5
+		// The open tag includes one trailing whitespace character
+		if len(rest) > 5 && isSpace(rest[5]) {
+			tagLen = 6
+		}
+	case strings.HasPrefix(rest, "<?="):
+		tagLen = 3
+	}
+	l.emit(OpenTag, l.pos+tagLen)
+}
+
+// lexPHP consumes PHP code until a close tag or end of input.
+func (l *lexer) lexPHP() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		rest := l.src[l.pos:]
+
+		switch {
+		case isSpace(c):
+			end := l.pos
+			for end < len(l.src) && isSpace(l.src[end]) {
+				end++
+			}
+			l.emit(Whitespace, end)
+
+		case strings.HasPrefix(rest, "?>"):
+			end := l.pos + 2
+			// A single newline directly after ?> belongs to the tag
+			if strings.HasPrefix(l.src[end:], "\r\n") {
+				end += 2
+			} else if end < len(l.src) && l.src[end] == '\n' {
+				end++
+			}
+			l.emit(CloseTag, end)
+			return
+
+		case strings.HasPrefix(rest, "#["):
+			l.emit(Punct, l.pos+2)
+
+		case c == '#' || strings.HasPrefix(rest, "//"):
+			l.emit(Comment, l.lineCommentEnd())
+
+		case strings.HasPrefix(rest, "/*"):
+			kind := Comment
+			if strings.HasPrefix(rest, "/**") && !strings.HasPrefix(rest, "/**/") {
+				kind = DocComment
+			}
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				l.emit(kind, len(l.src))
+			} else {
+				l.emit(kind, l.pos+2+end+2)
+			}
+
+		case c == '\'' || c == '"' || c == '`':
+			l.emit(String, l.quotedEnd(c))
+
+		case strings.HasPrefix(rest, "<<<"):
+			if end, ok := l.heredocEnd(); ok {
+				l.emit(Heredoc, end)
+			} else {
+				l.emit(Punct, l.pos+2)
+			}
+
+		case c == '$' && l.pos+1 < len(l.src) && isIdentStart(l.src[l.pos+1]):
+			l.emit(Variable, l.identEnd(l.pos+1))
+
+		case isIdentStart(c) || (c == '\\' && l.pos+1 < len(l.src) && isIdentStart(l.src[l.pos+1])):
+			l.lexName()
+
+		case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+			end := l.pos + 1
+			for end < len(l.src) && (isIdentChar(l.src[end]) || l.src[end] == '.') {
+				end++
+			}
+			l.emit(Number, end)
+
+		default:
+			l.lexOperator()
+		}
+	}
+}
+
+// lexName consumes an identifier or a namespaced name.
+func (l *lexer) lexName() {
+	start := l.pos
+	end := start
+	if l.src[end] == '\\' {
+		end++
+	}
+	end = l.identEnd(end)
+	for end+1 < len(l.src) && l.src[end] == '\\' && isIdentStart(l.src[end+1]) {
+		end = l.identEnd(end + 1)
+	}
+
+	text := l.src[start:end]
+	kind := Ident
+	switch {
+	case text[0] == '\\':
+		kind = NameFullyQualified
+	case len(text) > 10 && strings.EqualFold(text[:10], "namespace\\"):
+		kind = NameRelative
+	case strings.Contains(text, "\\"):
+		kind = NameQualified
+	}
+	l.emit(kind, end)
+}
+
+func (l *lexer) lexOperator() {
+	rest := l.src[l.pos:]
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			l.emit(Punct, l.pos+len(op))
+			return
+		}
+	}
+	l.emit(Punct, l.pos+1)
+}
+
+// lineCommentEnd finds the end of a // or # comment, which stops before a newline or ?>.
+func (l *lexer) lineCommentEnd() int {
+	for i := l.pos; i < len(l.src); i++ {
+		if l.src[i] == '\n' || l.src[i] == '\r' {
+			return i
+		}
+		if l.src[i] == '?' && i+1 < len(l.src) && l.src[i+1] == '>' {
+			return i
+		}
+	}
+	return len(l.src)
+}
+
+// quotedEnd finds the end of a string literal delimited by quote.
+func (l *lexer) quotedEnd(quote byte) int {
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(l.src)
+}
+
+// heredocEnd finds the end of a heredoc or nowdoc starting at l.pos.
+// PHP 7.3+ flexible syntax allows the closing marker to be indented.
+func (l *lexer) heredocEnd() (int, bool) {
+	i := l.pos + 3
+	for i < len(l.src) && (l.src[i] == ' ' || l.src[i] == '\t') {
+		i++
+	}
+	quote := byte(0)
+	if i < len(l.src) && (l.src[i] == '\'' || l.src[i] == '"') {
+		quote = l.src[i]
+		i++
+	}
+	if i >= len(l.src) || !isIdentStart(l.src[i]) {
+		return 0, false
+	}
+	idEnd := l.identEnd(i)
+	label := l.src[i:idEnd]
+	i = idEnd
+	if quote != 0 {
+		if i >= len(l.src) || l.src[i] != quote {
+			return 0, false
+		}
+		i++
+	}
+	if strings.HasPrefix(l.src[i:], "\r\n") {
+		i += 2
+	} else if i < len(l.src) && l.src[i] == '\n' {
+		i++
+	} else {
+		return 0, false
+	}
+
+	for i < len(l.src) {
+		lineStart := i
+		for i < len(l.src) && (l.src[i] == ' ' || l.src[i] == '\t') {
+			i++
+		}
+		if strings.HasPrefix(l.src[i:], label) {
+			after := i + len(label)
+			if after >= len(l.src) || !isIdentChar(l.src[after]) {
+				return after, true
+			}
+		}
+		nl := strings.IndexByte(l.src[lineStart:], '\n')
+		if nl < 0 {
+			break
+		}
+		i = lineStart + nl + 1
+	}
+	return len(l.src), true
+}
+
+func (l *lexer) identEnd(i int) int {
+	for i < len(l.src) && isIdentChar(l.src[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
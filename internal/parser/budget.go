@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+
+	"php-dep-extractor/internal/tokens"
+)
+
+// TokenReport lists the estimated token cost of the selected files and their
+// dependencies, and how they fit into a budget if one was requested.
+type TokenReport struct {
+	Files []tokens.File `json:"files"`
+	Total int           `json:"total"`
+	Plan  *tokens.Plan  `json:"plan,omitempty"`
+}
+
+// EstimateTokens estimates the tokens of the selected files, the
+// dependencies in result and the include targets that are exported with
+// them. With a positive budget it also plans which dependencies to stub or
+// drop, farthest first, so that the export fits.
+func EstimateTokens(selectedFiles []string, result *DependencyResult, includes []string, projectRoot string, budget int, allowStubs bool) *TokenReport {
+	report := &TokenReport{}
+
+	depths := make(map[string]int)
+	add := func(relPath string, depth int) {
+		if _, ok := depths[relPath]; ok {
+			return
+		}
+		depths[relPath] = depth
+		data, err := os.ReadFile(filepath.Join(projectRoot, filepath.FromSlash(relPath)))
+		if err != nil {
+			return
+		}
+		f := tokens.File{Path: relPath, Depth: depth, Tokens: tokens.Estimate(string(data))}
+		if budget > 0 && allowStubs && depth > 0 {
+			f.StubTokens = tokens.Estimate(Stub(string(data)))
+		}
+		report.Files = append(report.Files, f)
+		report.Total += f.Tokens
+	}
+
+	for _, f := range selectedFiles {
+		add(f, 0)
+	}
+	for _, dep := range result.Dependencies {
+		add(dep.FilePath, dep.Depth)
+	}
+	// An include target is one step farther than the file including it
+	for _, target := range includes {
+		depth := 1
+		for _, inc := range result.Includes {
+			if d, ok := depths[inc.SourceFile]; ok && inc.Resolved == target {
+				depth = d + 1
+				break
+			}
+		}
+		add(target, depth)
+	}
+
+	if budget > 0 {
+		report.Plan = tokens.Fit(report.Files, budget, allowStubs)
+	}
+	return report
+}
+
+// StubFilter returns a content filter that stubs the given files and leaves
+// all others untouched, for use with exports.
+func StubFilter(stubbed []string) func(relPath, content string) string {
+	set := make(map[string]bool, len(stubbed))
+	for _, p := range stubbed {
+		set[p] = true
+	}
+	return func(relPath, content string) string {
+		if set[relPath] {
+			return Stub(content)
+		}
+		return content
+	}
+}
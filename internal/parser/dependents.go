@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"context"
+
+	"php-dep-extractor/internal/progress"
+	"php-dep-extractor/internal/scanner"
+	"php-dep-extractor/internal/workers"
+)
+
+// ReferenceIndex is the reverse of the class index: for every file it lists
+// the references pointing at it from anywhere in the project.
+type ReferenceIndex struct {
+	Incoming map[string][]Edge // referenced file -> edges pointing at it
+}
+
+// Dependent is a file that references a target file directly or transitively.
+type Dependent struct {
+	FilePath   string `json:"filePath"`
+	ClassName  string `json:"className"`
+	RefType    string `json:"refType"`
+	References string `json:"references"` // which file FilePath references
+	Line       int    `json:"line"`       // line of the reference in FilePath
+	Depth      int    `json:"depth"`      // 1 = references a target directly
+}
+
+// BuildReferenceIndex records who references whom across all files.
+// References already extracted, e.g. from a cache, are taken from known;
+// other files are parsed. Like Resolve, repeated references of one type
+// between two files are kept once. Cancelling ctx stops parsing with ctx.Err().
+func BuildReferenceIndex(ctx context.Context, files []string, index *scanner.ClassIndex, projectRoot string, known map[string][]ClassReference) (*ReferenceIndex, error) {
+	ri := &ReferenceIndex{Incoming: make(map[string][]Edge)}
+	seenEdges := make(map[string]bool)
+
+	// Parse the files that aren't known yet in parallel
+	parsed := make([][]ClassReference, len(files))
+	counter := progress.Start(ctx, progress.PhaseReferences, len(files))
+	err := workers.ForEach(ctx, len(files), func(i int) {
+		if _, ok := known[files[i]]; !ok {
+			parsed[i], _ = ExtractClassRefs(projectRoot + "/" + files[i])
+		}
+		counter.Done(files[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, relPath := range files {
+		refs, ok := known[relPath]
+		if !ok {
+			refs = parsed[i]
+		}
+		deps, _ := resolveRefs(refs, relPath, index)
+		for _, dep := range deps {
+			edgeKey := dep.ReferencedBy + "|" + dep.FilePath + "|" + dep.RefType
+			if seenEdges[edgeKey] {
+				continue
+			}
+			seenEdges[edgeKey] = true
+			ri.Incoming[dep.FilePath] = append(ri.Incoming[dep.FilePath], Edge{
+				From:      dep.ReferencedBy,
+				To:        dep.FilePath,
+				ClassName: dep.ClassName,
+				RefType:   dep.RefType,
+				Line:      dep.Line,
+			})
+		}
+	}
+	return ri, nil
+}
+
+// Dependents lists the files referencing the targets. In transitive mode the
+// files referencing those dependents are added in turn until no new files
+// appear or MaxDepth is reached. ParseIncludes is ignored.
+func (ri *ReferenceIndex) Dependents(targets []string, opts ResolveOptions) []Dependent {
+	var result []Dependent
+	seen := make(map[string]bool)
+	for _, f := range targets {
+		seen[f] = true
+	}
+
+	current := targets
+	for depth := 1; len(current) > 0; depth++ {
+		var next []string
+
+		for _, target := range current {
+			for _, e := range ri.Incoming[target] {
+				if seen[e.From] {
+					continue
+				}
+				seen[e.From] = true
+				result = append(result, Dependent{
+					FilePath:   e.From,
+					ClassName:  e.ClassName,
+					RefType:    e.RefType,
+					References: e.To,
+					Line:       e.Line,
+					Depth:      depth,
+				})
+				next = append(next, e.From)
+			}
+		}
+
+		if !opts.Transitive || (opts.MaxDepth > 0 && depth >= opts.MaxDepth) {
+			break
+		}
+		current = next
+	}
+	return result
+}
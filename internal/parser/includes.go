@@ -5,6 +5,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"php-dep-extractor/internal/framework"
+	"php-dep-extractor/internal/lexer"
+	"php-dep-extractor/internal/scanner"
 )
 
 // IncludeRef represents a require/include statement found in a PHP file.
@@ -15,55 +19,163 @@ type IncludeRef struct {
 	Line     int    `json:"line"`
 }
 
-var (
-	reInclude = regexp.MustCompile(`(?:require_once|include_once|require|include)\s*[\(]?\s*(.+?)\s*[\)]?\s*;`)
-	reIncType = regexp.MustCompile(`(require_once|include_once|require|include)`)
-)
-
 // ExtractIncludes extracts require/include statements from a PHP file.
-func ExtractIncludes(filePath string, projectRoot string) ([]IncludeRef, error) {
+// Plain string paths are resolved if they contain a directory or end in one
+// of the extensions (normalized, nil means ".php"). Paths built with the
+// idioms of fw, which may be nil, are resolved if the target exists.
+func ExtractIncludes(filePath string, projectRoot string, extensions []string, fw framework.Framework) ([]IncludeRef, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(string(data), "\n")
+	src := string(data)
+	toks := lexer.Significant(lexer.Tokenize(src))
 	var refs []IncludeRef
 
 	fileDir := filepath.Dir(filePath)
+	defines := fileDefines(src, toks)
 
-	for i, line := range lines {
-		lineNum := i + 1
-		trimmed := strings.TrimSpace(line)
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok.Kind != lexer.Ident || !isIncludeKeyword(tok.Text) {
+			continue
+		}
 
-		// Skip comments
-		if strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "/*") {
+		// The path expression runs to the terminating ; or ?> at nesting depth 0
+		start, end := i+1, i+1
+		depth := 0
+		for ; end < len(toks); end++ {
+			t := toks[end]
+			if depth == 0 && (t.Is(";") || t.Kind == lexer.CloseTag) {
+				break
+			}
+			if t.Is("(") || t.Is("[") {
+				depth++
+			} else if t.Is(")") || t.Is("]") {
+				depth--
+			}
+		}
+		if end == start || end >= len(toks) {
 			continue
 		}
 
-		m := reInclude.FindStringSubmatch(line)
-		if len(m) < 2 {
+		// Drop parentheses wrapping the whole expression: require_once('x.php');
+		if toks[start].Is("(") && toks[end-1].Is(")") && closingParen(toks, start) == end-1 {
+			start++
+			end--
+		}
+		if end <= start {
 			continue
 		}
 
-		typeMatch := reIncType.FindString(line)
-		rawPath := strings.TrimSpace(m[1])
+		last := toks[end-1]
+		rawPath := strings.TrimSpace(src[toks[start].Pos : last.Pos+len(last.Text)])
+
+		// A constant defined in the file stands for its value:
+		// define('MY_DIR', plugin_dir_path(__FILE__)); require MY_DIR . 'x.php';
+		expr := rawPath
+		if value, ok := defines[toks[start].Text]; ok && toks[start].Kind == lexer.Ident {
+			expr = value + rawPath[len(toks[start].Text):]
+		}
+		expr = joinLiterals(expr)
 
-		resolved := resolveIncludePath(rawPath, fileDir, projectRoot)
+		resolved := resolveFrameworkPath(expr, filePath, projectRoot, fw)
+		if resolved == "" {
+			resolved = resolveIncludePath(expr, fileDir, projectRoot, extensions)
+		}
 
 		refs = append(refs, IncludeRef{
-			Type:     typeMatch,
+			Type:     strings.ToLower(tok.Text),
 			RawPath:  rawPath,
 			Resolved: resolved,
-			Line:     lineNum,
+			Line:     tok.Line,
 		})
 	}
 
 	return refs, nil
 }
 
+func isIncludeKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "require", "require_once", "include", "include_once":
+		return true
+	}
+	return false
+}
+
+// adjacentLiterals matches two concatenated plain string literals.
+var adjacentLiterals = regexp.MustCompile(`(['"])([^'"$]*)['"]\s*\.\s*['"]([^'"$]*)['"]`)
+
+// joinLiterals merges concatenated string literals: __DIR__ . '/lib' . '/x.php'
+// becomes __DIR__ . '/lib/x.php'.
+func joinLiterals(expr string) string {
+	for {
+		joined := adjacentLiterals.ReplaceAllString(expr, "$1$2$3$1")
+		if joined == expr {
+			return expr
+		}
+		expr = joined
+	}
+}
+
+// fileDefines returns the constants a file defines with define('NAME', expr)
+// and the source of their value expressions.
+func fileDefines(src string, toks []lexer.Token) map[string]string {
+	defines := make(map[string]string)
+	for i := 0; i+4 < len(toks); i++ {
+		if !toks[i].Is("define") || !toks[i+1].Is("(") || toks[i+2].Kind != lexer.String || !toks[i+3].Is(",") {
+			continue
+		}
+		end := closingParen(toks, i+1)
+		if end <= i+4 {
+			continue
+		}
+		last := toks[end-1]
+		defines[toks[i+2].StringValue()] = src[toks[i+4].Pos : last.Pos+len(last.Text)]
+	}
+	return defines
+}
+
+// resolveFrameworkPath resolves an include path built with the idioms of
+// fw, e.g. WordPress' plugin_dir_path(__FILE__) . 'x.php', if the target
+// exists.
+func resolveFrameworkPath(expr, filePath, projectRoot string, fw framework.Framework) string {
+	if fw == nil {
+		return ""
+	}
+	rel, err := filepath.Rel(projectRoot, filePath)
+	if err != nil {
+		return ""
+	}
+	p := fw.IncludePath(expr, filepath.ToSlash(rel))
+	if p == "" {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, filepath.FromSlash(p))); err != nil {
+		return ""
+	}
+	return p
+}
+
+// closingParen returns the index of the token closing the parenthesis at open, or -1.
+func closingParen(toks []lexer.Token, open int) int {
+	depth := 0
+	for i := open; i < len(toks); i++ {
+		if toks[i].Is("(") {
+			depth++
+		} else if toks[i].Is(")") {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // resolveIncludePath attempts to resolve a PHP include path expression to a relative path.
-func resolveIncludePath(rawPath string, fileDir string, projectRoot string) string {
+func resolveIncludePath(rawPath string, fileDir string, projectRoot string, extensions []string) string {
 	// Remove quotes for simple string paths
 	path := strings.Trim(rawPath, "'\"")
 
@@ -90,7 +202,7 @@ func resolveIncludePath(rawPath string, fileDir string, projectRoot string) stri
 	}
 
 	// Simple string path (no variables/concatenation)
-	if !strings.ContainsAny(path, "$.(") && (strings.HasSuffix(path, ".php") || strings.Contains(path, "/")) {
+	if isPlainString(rawPath) && (scanner.HasExtension(path, extensions) || strings.Contains(path, "/")) {
 		// Try relative to file directory
 		absPath := filepath.Join(fileDir, path)
 		if _, err := os.Stat(absPath); err == nil {
@@ -109,3 +221,17 @@ func resolveIncludePath(rawPath string, fileDir string, projectRoot string) stri
 
 	return ""
 }
+
+// isPlainString reports whether an expression is a single string literal
+// without interpolation, e.g. 'lib/x.php' but not 'lib/' . $name.
+func isPlainString(expr string) bool {
+	if len(expr) < 2 {
+		return false
+	}
+	q := expr[0]
+	if (q != '\'' && q != '"') || expr[len(expr)-1] != q {
+		return false
+	}
+	body := expr[1 : len(expr)-1]
+	return !strings.ContainsRune(body, rune(q)) && !(q == '"' && strings.Contains(body, "$"))
+}
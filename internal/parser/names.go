@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// Names that are never resolved against the current namespace.
+var reservedNames = map[string]bool{
+	"self": true, "static": true, "parent": true,
+	"null": true, "true": true, "false": true,
+	"int": true, "float": true, "string": true, "bool": true,
+	"array": true, "object": true, "void": true, "mixed": true,
+	"callable": true, "iterable": true, "never": true,
+}
+
+// nameScope tracks the namespace and class imports of a PHP file so that
+// class names can be resolved to fully-qualified names following PHP rules.
+type nameScope struct {
+	namespace string
+	imports   map[string]string // lowercased alias -> fully-qualified class name
+	depth     int               // brace depth at which use statements are imports
+}
+
+// classImport records a class imported by a use statement.
+type classImport struct {
+	Name  string // fully-qualified class name
+	Alias string
+	Line  int
+}
+
+func newNameScope() *nameScope {
+	return &nameScope{imports: make(map[string]string)}
+}
+
+// resolve returns the fully-qualified form of a class name as written in the source.
+func (s *nameScope) resolve(name string) string {
+	switch {
+	case strings.HasPrefix(name, "\\"):
+		return strings.TrimPrefix(name, "\\")
+	case len(name) > 10 && strings.EqualFold(name[:10], "namespace\\"):
+		return s.qualify(name[10:])
+	case reservedNames[strings.ToLower(name)]:
+		return name
+	}
+
+	first, rest, qualified := strings.Cut(name, "\\")
+	if target, ok := s.imports[strings.ToLower(first)]; ok {
+		if qualified {
+			return target + "\\" + rest
+		}
+		return target
+	}
+	return s.qualify(name)
+}
+
+func (s *nameScope) qualify(name string) string {
+	if s.namespace == "" {
+		return name
+	}
+	return s.namespace + "\\" + name
+}
+
+// parseNamespace handles a namespace declaration starting at toks[i] and
+// returns the index of its terminating token.
+func (s *nameScope) parseNamespace(toks []lexer.Token, i int, braceDepth int) int {
+	s.namespace = ""
+	s.imports = make(map[string]string)
+	s.depth = braceDepth
+
+	j := i + 1
+	if j < len(toks) && toks[j].IsName() {
+		s.namespace = strings.TrimPrefix(toks[j].Text, "\\")
+		j++
+	}
+	if j < len(toks) && toks[j].Is("{") {
+		s.depth = braceDepth + 1
+		// Leave the brace for the caller's depth tracking
+		return j - 1
+	}
+	return j
+}
+
+// parseUse handles a top-level use statement starting at toks[i], records
+// its class imports and returns them along with the index of the terminating token.
+func (s *nameScope) parseUse(toks []lexer.Token, i int) ([]classImport, int) {
+	var imports []classImport
+
+	j := i + 1
+	kind := ""
+	if j < len(toks) && (toks[j].Is("function") || toks[j].Is("const")) {
+		kind = strings.ToLower(toks[j].Text)
+		j++
+	}
+
+	add := func(name, alias, itemKind string, line int) {
+		name = strings.TrimPrefix(name, "\\")
+		if alias == "" {
+			alias = name[strings.LastIndex(name, "\\")+1:]
+		}
+		if itemKind != "" {
+			// Function and constant imports don't name classes
+			return
+		}
+		s.imports[strings.ToLower(alias)] = name
+		imports = append(imports, classImport{Name: name, Alias: alias, Line: line})
+	}
+
+	for j < len(toks) && !toks[j].Is(";") {
+		if !toks[j].IsName() {
+			j++
+			continue
+		}
+		name := toks[j]
+		j++
+
+		// Grouped import: use Prefix\{A, B as C};
+		if j+1 < len(toks) && toks[j].Is("\\") && toks[j+1].Is("{") {
+			prefix := strings.TrimPrefix(name.Text, "\\")
+			j += 2
+			for j < len(toks) && !toks[j].Is("}") {
+				itemKind := kind
+				if toks[j].Is("function") || toks[j].Is("const") {
+					itemKind = strings.ToLower(toks[j].Text)
+					j++
+				}
+				if j >= len(toks) || !toks[j].IsName() {
+					j++
+					continue
+				}
+				item := toks[j]
+				j++
+				alias := ""
+				if j+1 < len(toks) && toks[j].Is("as") {
+					alias = toks[j+1].Text
+					j += 2
+				}
+				add(prefix+"\\"+item.Text, alias, itemKind, item.Line)
+				if j < len(toks) && toks[j].Is(",") {
+					j++
+				}
+			}
+			continue
+		}
+
+		alias := ""
+		if j+1 < len(toks) && toks[j].Is("as") {
+			alias = toks[j+1].Text
+			j += 2
+		}
+		add(name.Text, alias, kind, name.Line)
+	}
+
+	return imports, j
+}
@@ -2,15 +2,19 @@ package parser
 
 import (
 	"os"
-	"regexp"
+	"slices"
 	"strings"
+
+	"php-dep-extractor/internal/framework"
+	"php-dep-extractor/internal/lexer"
 )
 
 // ClassReference represents a found class reference in a PHP file.
 type ClassReference struct {
-	ClassName string `json:"className"`
-	RefType   string `json:"refType"` // "new", "extends", "implements", "static", "typehint", "use"
-	Line      int    `json:"line"`
+	ClassName  string `json:"className"` // fully-qualified, without leading backslash; the name for template and route references
+	RefType    string `json:"refType"`   // "new", "extends", "implements", "static", "typehint", "use", "trait"
+	Line       int    `json:"line"`
+	SourceFile string `json:"sourceFile,omitempty"` // set by Resolve for unresolved references
 }
 
 // PHP built-in types/classes to exclude.
@@ -37,51 +41,35 @@ var builtinClasses = map[string]bool{
 	"callable": true, "iterable": true, "never": true,
 }
 
-// Framework prefixes to exclude.
-var frameworkPrefixes = []string{
-	"Zend_", "ZendX_",
-	"Cake", // CakePHP core
-	"Illuminate\\",
-	"Symfony\\",
+// Library prefixes to exclude, besides the core prefixes of every
+// registered framework.
+var libraryPrefixes = []string{
 	"PHPUnit",
 }
 
-// Regex patterns for class references.
-var (
-	reNew        = regexp.MustCompile(`new\s+([A-Z]\w+)`)
-	reExtends    = regexp.MustCompile(`extends\s+([A-Z]\w+)`)
-	reImplements = regexp.MustCompile(`implements\s+(.+?)[\s{]`)
-	reStatic     = regexp.MustCompile(`([A-Z]\w+)::`)
-	reTypeHint   = regexp.MustCompile(`(?:function\s+\w+\s*\([^)]*?)([A-Z]\w+)\s+\$`)
-	reUseStmt    = regexp.MustCompile(`^use\s+(App\\[\w\\]+)`)
-	// ZF1 style: class names with underscores like Model_Car_CarrierCust
-	reZF1Class = regexp.MustCompile(`new\s+([A-Z]\w*(?:_\w+)+)`)
-	// CakePHP App::uses
-	reCakeUses  = regexp.MustCompile(`App::uses\s*\(\s*'(\w+)'`)
-	reCakeImport = regexp.MustCompile(`App::import\s*\(\s*'(\w+)'\s*,\s*'(\w+)'`)
-)
-
 // ExtractClassRefs extracts all class references from a PHP file.
 func ExtractClassRefs(filePath string) ([]ClassReference, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
+	return ClassRefs(string(data)), nil
+}
 
-	content := string(data)
-	lines := strings.Split(content, "\n")
+// ClassRefs extracts all class references from PHP source. The references
+// and core classes of all registered frameworks are taken into account, so
+// the result doesn't depend on the project's framework and can be cached.
+func ClassRefs(src string) []ClassReference {
+	toks := lexer.Significant(lexer.Tokenize(src))
 
 	var refs []ClassReference
 	seen := make(map[string]bool)
+	scope := newNameScope()
+	frameworks := framework.All()
+	excluded := excludedPrefixes(frameworks)
 
-	addRef := func(className, refType string, line int) {
-		className = strings.TrimSpace(className)
-		if className == "" || builtinClasses[className] {
-			return
-		}
-		if isFrameworkClass(className) {
-			return
-		}
+	// addName records a name once per reference type
+	addName := func(className, refType string, line int) {
 		key := className + "|" + refType
 		if !seen[key] {
 			seen[key] = true
@@ -93,68 +81,187 @@ func ExtractClassRefs(filePath string) ([]ClassReference, error) {
 		}
 	}
 
-	for i, line := range lines {
-		lineNum := i + 1
-		trimmed := strings.TrimSpace(line)
-
-		// Skip comments
-		if strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "/*") {
-			continue
+	// addResolved records a fully-qualified class name
+	addResolved := func(className, refType string, line int) {
+		if className == "" || builtinClasses[className] {
+			return
+		}
+		if hasAnyPrefix(className, excluded) {
+			return
 		}
+		addName(className, refType, line)
+	}
 
-		// new ClassName
-		for _, m := range reNew.FindAllStringSubmatch(line, -1) {
-			addRef(m[1], "new", lineNum)
+	// addRef records a class name as written in the source
+	addRef := func(name, refType string, line int) {
+		name = strings.TrimSpace(name)
+		if name == "" || reservedNames[strings.ToLower(name)] {
+			return
 		}
-		// ZF1 style new with underscores
-		for _, m := range reZF1Class.FindAllStringSubmatch(line, -1) {
-			addRef(m[1], "new", lineNum)
+		addResolved(scope.resolve(name), refType, line)
+	}
+
+	at := func(i int) lexer.Token {
+		if i < 0 || i >= len(toks) {
+			return lexer.Token{Kind: lexer.Whitespace}
 		}
+		return toks[i]
+	}
+
+	braceDepth := 0
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
 
-		// extends ClassName
-		if m := reExtends.FindStringSubmatch(line); len(m) > 1 {
-			addRef(m[1], "extends", lineNum)
+		for _, fw := range frameworks {
+			for _, r := range fw.RefsAt(toks, i) {
+				if framework.IsNameRef(r.RefType) {
+					addName(r.Name, r.RefType, r.Line)
+				} else {
+					addRef(r.Name, r.RefType, r.Line)
+				}
+			}
 		}
 
+		switch {
+		case tok.Is("{"):
+			braceDepth++
+
+		case tok.Is("}"):
+			braceDepth--
+			// End of a braced namespace block
+			if braceDepth < scope.depth {
+				scope = newNameScope()
+			}
+
+		case tok.Is("namespace") && !at(i+1).Is("("):
+			i = scope.parseNamespace(toks, i, braceDepth)
+
+		// Imports at file or namespace level. A closure's use clause,
+		// function () use ($x), is no statement of its own.
+		case tok.Is("use") && braceDepth == scope.depth && !at(i+1).Is("(") && startsStatement(at(i-1)):
+			var imports []classImport
+			imports, i = scope.parseUse(toks, i)
+			for _, imp := range imports {
+				addResolved(imp.Name, "use", imp.Line)
+			}
+
+		// Trait use inside a class body (closures use "use (")
+		case tok.Is("use") && !at(i+1).Is("("):
+			for j := i + 1; at(j).IsName(); j += 2 {
+				addRef(at(j).Text, "trait", at(j).Line)
+				if !at(j + 1).Is(",") {
+					break
+				}
+			}
+
+		// new ClassName
+		case tok.Is("new"):
+			if next := at(i + 1); next.IsName() && !next.Is("class") {
+				addRef(next.Text, "new", next.Line)
+			}
+
+		// extends ClassName (interfaces may extend several)
+		case tok.Is("extends"):
+			for j := i + 1; at(j).IsName(); j += 2 {
+				addRef(at(j).Text, "extends", at(j).Line)
+				if !at(j + 1).Is(",") {
+					break
+				}
+			}
+
 		// implements Interface1, Interface2
-		if m := reImplements.FindStringSubmatch(line); len(m) > 1 {
-			for _, iface := range strings.Split(m[1], ",") {
-				addRef(strings.TrimSpace(iface), "implements", lineNum)
+		case tok.Is("implements"):
+			for j := i + 1; at(j).IsName(); j += 2 {
+				addRef(at(j).Text, "implements", at(j).Line)
+				if !at(j + 1).Is(",") {
+					break
+				}
+			}
+
+		// Type hints in function params
+		case tok.Is("function") || tok.Is("fn"):
+			j := i + 1
+			if at(j).Is("&") {
+				j++
+			}
+			if at(j).Kind == lexer.Ident {
+				j++
+			}
+			if at(j).Is("(") {
+				extractTypeHints(toks, j, addRef)
 			}
-		}
 
 		// ClassName::method()
-		for _, m := range reStatic.FindAllStringSubmatch(line, -1) {
-			name := m[1]
+		case tok.IsName() && at(i+1).Is("::"):
+			name := strings.ToLower(tok.Text)
 			if name != "self" && name != "static" && name != "parent" {
-				addRef(name, "static", lineNum)
+				addRef(tok.Text, "static", tok.Line)
 			}
 		}
+	}
 
-		// Type hints in function params
-		for _, m := range reTypeHint.FindAllStringSubmatch(line, -1) {
-			addRef(m[1], "typehint", lineNum)
-		}
+	return refs
+}
 
-		// Laravel use statements
-		if m := reUseStmt.FindStringSubmatch(trimmed); len(m) > 1 {
-			addRef(m[1], "use", lineNum)
-		}
+// startsStatement reports whether a token following prev starts a
+// statement. Before the first token, prev is whitespace.
+func startsStatement(prev lexer.Token) bool {
+	return prev.Kind == lexer.Whitespace || prev.Kind == lexer.CloseTag ||
+		prev.Is(";") || prev.Is("{") || prev.Is("}")
+}
 
-		// CakePHP App::uses
-		for _, m := range reCakeUses.FindAllStringSubmatch(line, -1) {
-			addRef(m[1], "uses", lineNum)
-		}
-		for _, m := range reCakeImport.FindAllStringSubmatch(line, -1) {
-			addRef(m[2], "import", lineNum)
+// Modifiers of promoted constructor parameters.
+var paramModifiers = map[string]bool{"public": true, "protected": true, "private": true, "readonly": true}
+
+// extractTypeHints reports class names used as parameter types in the
+// parameter list opening at toks[open]. Default values and attributes
+// like #[SensitiveParameter] are skipped.
+func extractTypeHints(toks []lexer.Token, open int, addRef func(string, string, int)) {
+	depth := 0
+	inDefault := false
+	for j := open; j < len(toks); j++ {
+		t := toks[j]
+		switch {
+		case t.Is("(") || t.Is("[") || t.Is("{"):
+			depth++
+		case t.Is("#["):
+			// Skip to the end of the attribute
+			for d := 1; d > 0 && j+1 < len(toks); {
+				j++
+				switch a := toks[j]; {
+				case a.Is("(") || a.Is("[") || a.Is("{") || a.Is("#["):
+					d++
+				case a.Is(")") || a.Is("]") || a.Is("}"):
+					d--
+				}
+			}
+		case t.Is(")") || t.Is("]") || t.Is("}"):
+			depth--
+			if depth == 0 {
+				return
+			}
+		case depth == 1 && t.Is(","):
+			inDefault = false
+		case depth == 1 && t.Is("="):
+			inDefault = true
+		case depth == 1 && !inDefault && t.IsName() && !paramModifiers[strings.ToLower(t.Text)]:
+			addRef(t.Text, "typehint", t.Line)
 		}
 	}
+}
 
-	return refs, nil
+// excludedPrefixes returns the library prefixes and the core prefixes of
+// the frameworks.
+func excludedPrefixes(frameworks []framework.Framework) []string {
+	prefixes := slices.Clone(libraryPrefixes)
+	for _, fw := range frameworks {
+		prefixes = append(prefixes, fw.CorePrefixes()...)
+	}
+	return prefixes
 }
 
-func isFrameworkClass(name string) bool {
-	for _, prefix := range frameworkPrefixes {
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
 		if strings.HasPrefix(name, prefix) {
 			return true
 		}
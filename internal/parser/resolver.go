@@ -1,108 +1,126 @@
 package parser
 
 import (
+	"context"
+	"slices"
+
+	"php-dep-extractor/internal/framework"
+	"php-dep-extractor/internal/progress"
 	"php-dep-extractor/internal/scanner"
-	"strings"
+	"php-dep-extractor/internal/workers"
 )
 
 // DependencyResult holds analysis results for selected files.
 type DependencyResult struct {
-	Dependencies []Dependency  `json:"dependencies"`
-	Includes     []IncludeItem `json:"includes"`
+	Dependencies []Dependency     `json:"dependencies"`
+	Includes     []IncludeItem    `json:"includes"`
+	Unresolved   []ClassReference `json:"unresolved"` // references not found in the class index
+	Edges        []Edge           `json:"edges"`      // every resolved file-to-file reference
 }
 
 // Dependency represents a resolved class dependency.
 type Dependency struct {
-	ClassName  string `json:"className"`
-	FilePath   string `json:"filePath"`
-	RefType    string `json:"refType"`
-	ReferencedBy string `json:"referencedBy"` // which selected file references this
+	ClassName    string `json:"className"`
+	FilePath     string `json:"filePath"`
+	RefType      string `json:"refType"`
+	ReferencedBy string `json:"referencedBy"` // which file references this
+	Line         int    `json:"line"`         // line of the reference in ReferencedBy
+	Depth        int    `json:"depth"`        // 1 = referenced directly by a selected file
+}
+
+// Edge is one reference between two files in the dependency graph.
+// Unlike Dependencies, edges are kept for files that were already found.
+type Edge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	ClassName string `json:"className"`
+	RefType   string `json:"refType"`
+	Line      int    `json:"line"`
 }
 
 // IncludeItem represents a found include/require reference.
 type IncludeItem struct {
-	Type         string `json:"type"`
-	RawPath      string `json:"rawPath"`
-	Resolved     string `json:"resolved"`
-	Line         int    `json:"line"`
-	SourceFile   string `json:"sourceFile"`
+	Type       string `json:"type"`
+	RawPath    string `json:"rawPath"`
+	Resolved   string `json:"resolved"`
+	Line       int    `json:"line"`
+	SourceFile string `json:"sourceFile"`
+}
+
+// ResolveOptions controls how far Resolve follows dependencies.
+type ResolveOptions struct {
+	ParseIncludes bool
+	Transitive    bool // keep resolving newly found dependency files
+	MaxDepth      int  // limit for transitive mode; 0 means no limit
+
+	// Extensions of the project's PHP files, e.g. from ScanResult.Extensions.
+	// Plain include paths with one of them are resolved; nil means ".php".
+	Extensions []string
 }
 
 // Resolve takes selected files and finds all their class dependencies.
-func Resolve(selectedFiles []string, index *scanner.ClassIndex, projectRoot string, parseIncludes bool) (*DependencyResult, error) {
+// In transitive mode every newly discovered dependency file is analyzed in
+// turn until no new files appear or MaxDepth is reached. Progress is
+// reported per parsed file; cancelling ctx stops the analysis with ctx.Err().
+func Resolve(ctx context.Context, selectedFiles []string, index *scanner.ClassIndex, projectRoot string, opts ResolveOptions) (*DependencyResult, error) {
 	result := &DependencyResult{}
-	seenDeps := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, f := range selectedFiles {
+		seen[f] = true
+	}
+	seenEdges := make(map[string]bool)
 
-	for _, relPath := range selectedFiles {
-		absPath := projectRoot + "/" + relPath
+	current := selectedFiles
+	counter := progress.Start(ctx, progress.PhaseResolve, len(current))
+	for depth := 1; len(current) > 0; depth++ {
+		var next []string
 
-		// Extract class references
-		refs, err := ExtractClassRefs(absPath)
+		// Read and parse the files of this level in parallel, then merge the
+		// results in order so the output doesn't depend on scheduling
+		parsed := make([]parsedFile, len(current))
+		err := workers.ForEach(ctx, len(current), func(i int) {
+			absPath := projectRoot + "/" + current[i]
+			parsed[i].refs, parsed[i].err = ExtractClassRefs(absPath)
+			// Includes are only reported for the selected files
+			if opts.ParseIncludes && depth == 1 && parsed[i].err == nil {
+				parsed[i].includes, _ = ExtractIncludes(absPath, projectRoot, opts.Extensions, index.Framework)
+			}
+			counter.Done(current[i])
+		})
 		if err != nil {
-			continue
+			return nil, err
 		}
 
-		for _, ref := range refs {
-			className := ref.ClassName
-
-			// Try direct lookup
-			if depPath, ok := index.ClassToFile[className]; ok {
-				key := depPath
-				if !seenDeps[key] && !isSelected(depPath, selectedFiles) {
-					seenDeps[key] = true
-					result.Dependencies = append(result.Dependencies, Dependency{
-						ClassName:    className,
-						FilePath:     depPath,
-						RefType:      ref.RefType,
-						ReferencedBy: relPath,
-					})
-				}
+		for i, relPath := range current {
+			if parsed[i].err != nil {
+				continue
 			}
+			deps, unresolved := resolveRefs(parsed[i].refs, relPath, index)
+			result.Unresolved = append(result.Unresolved, unresolved...)
 
-			// For ZF1: try resolving underscore-separated names
-			// If className is like "CarrierCust" try common prefixes
-			if _, ok := index.ClassToFile[className]; !ok {
-				for _, prefix := range []string{"Model_", "DbTable_", "Service_", "Parent_", "Form_"} {
-					fullName := prefix + className
-					if depPath, ok := index.ClassToFile[fullName]; ok {
-						key := depPath
-						if !seenDeps[key] && !isSelected(depPath, selectedFiles) {
-							seenDeps[key] = true
-							result.Dependencies = append(result.Dependencies, Dependency{
-								ClassName:    fullName,
-								FilePath:     depPath,
-								RefType:      ref.RefType,
-								ReferencedBy: relPath,
-							})
-						}
-					}
+			for _, dep := range deps {
+				edgeKey := dep.ReferencedBy + "|" + dep.FilePath + "|" + dep.RefType
+				if !seenEdges[edgeKey] {
+					seenEdges[edgeKey] = true
+					result.Edges = append(result.Edges, Edge{
+						From:      dep.ReferencedBy,
+						To:        dep.FilePath,
+						ClassName: dep.ClassName,
+						RefType:   dep.RefType,
+						Line:      dep.Line,
+					})
 				}
-			}
 
-			// For Laravel: resolve short class name via use statements
-			if strings.Contains(className, "\\") {
-				if depPath, ok := index.ClassToFile[className]; ok {
-					key := depPath
-					if !seenDeps[key] && !isSelected(depPath, selectedFiles) {
-						seenDeps[key] = true
-						result.Dependencies = append(result.Dependencies, Dependency{
-							ClassName:    className,
-							FilePath:     depPath,
-							RefType:      ref.RefType,
-							ReferencedBy: relPath,
-						})
-					}
+				if seen[dep.FilePath] {
+					continue
 				}
+				seen[dep.FilePath] = true
+				dep.Depth = depth
+				result.Dependencies = append(result.Dependencies, dep)
+				next = append(next, dep.FilePath)
 			}
-		}
 
-		// Extract includes if enabled
-		if parseIncludes {
-			includes, err := ExtractIncludes(absPath, projectRoot)
-			if err != nil {
-				continue
-			}
-			for _, inc := range includes {
+			for _, inc := range parsed[i].includes {
 				result.Includes = append(result.Includes, IncludeItem{
 					Type:       inc.Type,
 					RawPath:    inc.RawPath,
@@ -112,16 +130,90 @@ func Resolve(selectedFiles []string, index *scanner.ClassIndex, projectRoot stri
 				})
 			}
 		}
+
+		if !opts.Transitive || (opts.MaxDepth > 0 && depth >= opts.MaxDepth) {
+			break
+		}
+		current = next
+		counter.Grow(len(next))
 	}
 
 	return result, nil
 }
 
-func isSelected(path string, selected []string) bool {
-	for _, s := range selected {
-		if s == path {
-			return true
+// parsedFile holds what Resolve extracted from one file.
+type parsedFile struct {
+	refs     []ClassReference
+	err      error
+	includes []IncludeRef
+}
+
+// resolveRefs looks up the class references of one file in the index.
+// References that can't be found are returned separately.
+func resolveRefs(refs []ClassReference, relPath string, index *scanner.ClassIndex) ([]Dependency, []ClassReference) {
+	var deps []Dependency
+	var unresolved []ClassReference
+	add := func(className, depPath string, ref ClassReference) {
+		if depPath == relPath {
+			return
+		}
+		deps = append(deps, Dependency{
+			ClassName:    className,
+			FilePath:     depPath,
+			RefType:      ref.RefType,
+			ReferencedBy: relPath,
+			Line:         ref.Line,
+		})
+	}
+
+	// Configuration may add references, e.g. services a definition injects
+	if extra := index.Project.Refs[relPath]; len(extra) > 0 {
+		refs = slices.Clip(refs)
+		for _, r := range extra {
+			refs = append(refs, ClassReference{ClassName: r.Name, RefType: r.RefType, Line: r.Line})
+		}
+	}
+
+	for _, ref := range refs {
+		className := ref.ClassName
+
+		// Templates, routes and the like are looked up by name, if the
+		// framework uses them at all
+		if framework.IsNameRef(ref.RefType) {
+			names := index.Project.Names[ref.RefType]
+			if depPath, ok := names[className]; ok {
+				add(className, depPath, ref)
+			} else if names != nil && !index.Project.Optional[ref.RefType] {
+				ref.SourceFile = relPath
+				unresolved = append(unresolved, ref)
+			}
+			continue
+		}
+
+		// Try direct lookup
+		if depPath, ok := index.ClassToFile[className]; ok {
+			add(className, depPath, ref)
+			continue
+		}
+
+		// The framework's conventions may place the class under another
+		// name, e.g. a ZF1 class referenced without its prefix
+		found := false
+		if fw := index.Framework; fw != nil {
+			for _, p := range fw.CandidatePaths(className, index.Mappings) {
+				fullName := fw.ClassFromPath(p, index.Mappings)
+				if depPath, ok := index.ClassToFile[fullName]; ok && fullName != "" {
+					add(fullName, depPath, ref)
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			ref.SourceFile = relPath
+			unresolved = append(unresolved, ref)
 		}
 	}
-	return false
+
+	return deps, unresolved
 }
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// stubBody replaces elided function bodies.
+const stubBody = "{ /* ... */ }"
+
+// Stub reduces PHP source to its declarations by replacing every function
+// and method body with an empty placeholder. Class structure, properties,
+// constants and signatures are kept, which is usually all an AI assistant
+// needs from a distant dependency.
+func Stub(src string) string {
+	toks := lexer.Tokenize(src)
+	var sb strings.Builder
+	sb.Grow(len(src) / 2)
+
+	for i := 0; i < len(toks); i++ {
+		sb.WriteString(toks[i].Text)
+		if !toks[i].Is("function") {
+			continue
+		}
+
+		open := functionBodyStart(toks, i+1)
+		if open < 0 {
+			continue
+		}
+		end := matchingBrace(toks, open)
+
+		// Keep everything up to the body, then the placeholder
+		for j := i + 1; j < open; j++ {
+			sb.WriteString(toks[j].Text)
+		}
+		sb.WriteString(stubBody)
+		i = end
+	}
+	return sb.String()
+}
+
+// functionBodyStart returns the index of the "{" opening the body of the
+// function whose signature starts at from, or -1 for bodiless declarations
+// (abstract and interface methods).
+func functionBodyStart(toks []lexer.Token, from int) int {
+	depth := 0
+	for j := from; j < len(toks); j++ {
+		t := toks[j]
+		switch {
+		case t.Is("(") || t.Is("[") || t.Is("#["):
+			depth++
+		case t.Is(")") || t.Is("]"):
+			depth--
+		case depth == 0 && t.Is("{"):
+			return j
+		case depth == 0 && (t.Is(";") || t.Is("=>")):
+			return -1
+		}
+	}
+	return -1
+}
+
+// matchingBrace returns the index of the "}" closing the brace at open,
+// or the last token if the file ends first.
+func matchingBrace(toks []lexer.Token, open int) int {
+	depth := 0
+	for j := open; j < len(toks); j++ {
+		switch {
+		case toks[j].Is("{"):
+			depth++
+		case toks[j].Is("}"):
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(toks) - 1
+}
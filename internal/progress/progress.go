@@ -0,0 +1,80 @@
+package progress
+
+import (
+	"context"
+	"sync"
+)
+
+// Phases reported in Event.Phase.
+const (
+	PhaseScan       = "scan"       // reading directories; Total grows as subdirectories are found
+	PhaseParse      = "parse"      // parsing new and changed files
+	PhaseIndex      = "index"      // building the class index
+	PhaseReferences = "references" // building the project-wide reference index
+	PhaseResolve    = "resolve"    // resolving dependencies; Total grows in transitive mode
+)
+
+// Event reports how far a phase has got.
+type Event struct {
+	Phase string `json:"phase"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	Path  string `json:"path,omitempty"` // item just finished
+}
+
+// Func receives progress events. Calls for one phase are serialized, but
+// phases of concurrent operations may report at the same time.
+type Func func(Event)
+
+type funcKey struct{}
+
+// WithFunc returns a context whose operations report progress to fn.
+func WithFunc(ctx context.Context, fn Func) context.Context {
+	return context.WithValue(ctx, funcKey{}, fn)
+}
+
+// Counter counts finished items of one phase. It is safe for concurrent use
+// and does nothing if the context has no progress function.
+type Counter struct {
+	mu    sync.Mutex
+	fn    Func
+	phase string
+	done  int
+	total int
+}
+
+// Start begins a phase with the given number of items.
+func Start(ctx context.Context, phase string, total int) *Counter {
+	fn, _ := ctx.Value(funcKey{}).(Func)
+	c := &Counter{fn: fn, phase: phase, total: total}
+	c.report("")
+	return c
+}
+
+// Done marks one item as finished.
+func (c *Counter) Done(path string) {
+	if c.fn == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done++
+	c.report(path)
+}
+
+// Grow adds items to a phase whose size isn't known up front.
+func (c *Counter) Grow(n int) {
+	if c.fn == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += n
+}
+
+// report must be called with mu held or before the counter is shared.
+func (c *Counter) report(path string) {
+	if c.fn != nil {
+		c.fn(Event{Phase: c.phase, Done: c.done, Total: c.total, Path: path})
+	}
+}
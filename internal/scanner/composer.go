@@ -0,0 +1,162 @@
+package scanner
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ComposerAutoload holds the autoload rules of a composer.json file,
+// merged from its "autoload" and "autoload-dev" sections.
+type ComposerAutoload struct {
+	PSR4     []AutoloadRule
+	PSR0     []AutoloadRule
+	Classmap []string // relative files or directories
+	Files    []string // relative files loaded on every request
+}
+
+// AutoloadRule maps a namespace (or PEAR-style) prefix to a directory.
+type AutoloadRule struct {
+	Prefix string
+	Dir    string // relative to the project root, no trailing slash
+}
+
+type composerSection struct {
+	PSR4     map[string]json.RawMessage `json:"psr-4"`
+	PSR0     map[string]json.RawMessage `json:"psr-0"`
+	Classmap []string                   `json:"classmap"`
+	Files    []string                   `json:"files"`
+}
+
+// LoadComposer reads the autoload configuration from composer.json in root.
+// It returns nil without error when the file doesn't exist.
+func LoadComposer(root string) (*ComposerAutoload, error) {
+	data, err := os.ReadFile(filepath.Join(root, "composer.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Autoload    composerSection `json:"autoload"`
+		AutoloadDev composerSection `json:"autoload-dev"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	al := &ComposerAutoload{}
+	for _, sec := range []composerSection{doc.Autoload, doc.AutoloadDev} {
+		al.PSR4 = append(al.PSR4, autoloadRules(sec.PSR4)...)
+		al.PSR0 = append(al.PSR0, autoloadRules(sec.PSR0)...)
+		for _, p := range sec.Classmap {
+			al.Classmap = append(al.Classmap, cleanRelPath(p))
+		}
+		for _, p := range sec.Files {
+			al.Files = append(al.Files, cleanRelPath(p))
+		}
+	}
+
+	// The most specific directory wins when rules are nested
+	sortRules := func(rules []AutoloadRule) {
+		sort.SliceStable(rules, func(i, j int) bool {
+			return len(rules[i].Dir) > len(rules[j].Dir)
+		})
+	}
+	sortRules(al.PSR4)
+	sortRules(al.PSR0)
+
+	return al, nil
+}
+
+// HasRules reports whether any autoload rule is declared.
+func (al *ComposerAutoload) HasRules() bool {
+	return len(al.PSR4)+len(al.PSR0)+len(al.Classmap)+len(al.Files) > 0
+}
+
+// autoloadRules expands a psr-4/psr-0 map whose values are a path or a list of paths.
+func autoloadRules(m map[string]json.RawMessage) []AutoloadRule {
+	var rules []AutoloadRule
+	for prefix, raw := range m {
+		var dirs []string
+		var single string
+		if err := json.Unmarshal(raw, &single); err == nil {
+			dirs = []string{single}
+		} else if err := json.Unmarshal(raw, &dirs); err != nil {
+			continue
+		}
+		for _, d := range dirs {
+			rules = append(rules, AutoloadRule{Prefix: prefix, Dir: cleanRelPath(d)})
+		}
+	}
+	// Map iteration order is random; keep the result stable
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Prefix != rules[j].Prefix {
+			return rules[i].Prefix < rules[j].Prefix
+		}
+		return rules[i].Dir < rules[j].Dir
+	})
+	return rules
+}
+
+// cleanRelPath normalizes a composer path like "./src/" to "src". The root itself becomes "".
+func cleanRelPath(p string) string {
+	p = path.Clean(filepath.ToSlash(p))
+	if p == "." {
+		return ""
+	}
+	return strings.TrimPrefix(p, "./")
+}
+
+// ClassFromPath derives the class name for a relative file path from the
+// psr-4 and psr-0 rules. Classmap and files entries are not path-based, so
+// BuildIndex finds their classes by reading the file.
+func (al *ComposerAutoload) ClassFromPath(relPath string) string {
+	if !strings.HasSuffix(relPath, ".php") {
+		return ""
+	}
+
+	for _, r := range al.PSR4 {
+		rest, ok := underDir(relPath, r.Dir)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimSuffix(rest, ".php")
+		return r.Prefix + strings.ReplaceAll(rest, "/", "\\")
+	}
+
+	for _, r := range al.PSR0 {
+		rest, ok := underDir(relPath, r.Dir)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimSuffix(rest, ".php")
+		// PEAR-style prefixes use underscores as separators
+		sep := "\\"
+		if r.Prefix != "" && !strings.Contains(r.Prefix, "\\") && strings.HasSuffix(r.Prefix, "_") {
+			sep = "_"
+		}
+		className := strings.ReplaceAll(rest, "/", sep)
+		if strings.HasPrefix(className, r.Prefix) {
+			return className
+		}
+	}
+
+	return ""
+}
+
+// underDir returns relPath relative to dir if it lies inside it.
+func underDir(relPath, dir string) (string, bool) {
+	if dir == "" {
+		return relPath, true
+	}
+	if strings.HasPrefix(relPath, dir+"/") {
+		return strings.TrimPrefix(relPath, dir+"/"), true
+	}
+	return "", false
+}
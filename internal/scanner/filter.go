@@ -0,0 +1,253 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"php-dep-extractor/internal/framework"
+)
+
+// Filter selects the files Scan collects: files with one of the extensions
+// that pass the include and exclude patterns. Patterns use .gitignore syntax:
+// "*", "?" and "[...]" match within one path segment, "**" matches any
+// number of segments, a pattern without a slash matches a name at any
+// depth, and a trailing slash matches directories only. ".gitignore" files
+// found in the tree are honoured as well.
+type Filter struct {
+	Extensions []string `json:"extensions"` // e.g. ".php"; empty means ".php" only
+	Include    []string `json:"include"`    // if set, only files matching one of these are collected
+	Exclude    []string `json:"exclude"`    // files and directories to skip
+}
+
+// DefaultExcludes are the directories skipped unless configured otherwise.
+func DefaultExcludes() []string {
+	return []string{"vendor/", "node_modules/", ".git/", ".svn/", ".idea/"}
+}
+
+// DefaultExtensions returns the file extensions scanned for a framework:
+// .php plus the view and include files of its conventions.
+func DefaultExtensions(fw Framework) []string {
+	if conv := framework.Lookup(string(fw)); conv != nil {
+		return conv.Extensions()
+	}
+	return []string{".php"}
+}
+
+// DefaultFilter returns a filter with the default excludes. Extensions are
+// left empty so the framework chosen at scan time picks them.
+func DefaultFilter() Filter {
+	return Filter{Exclude: DefaultExcludes()}
+}
+
+// NormalizeExtensions lowercases extensions and adds the leading dot.
+// Blank entries are dropped.
+func NormalizeExtensions(exts []string) []string {
+	var out []string
+	for _, e := range exts {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || e == "." {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// HasExtension reports whether name ends in one of the extensions, which
+// must be normalized. No extensions means ".php".
+func HasExtension(name string, exts []string) bool {
+	name = strings.ToLower(name)
+	if len(exts) == 0 {
+		return strings.HasSuffix(name, ".php")
+	}
+	for _, e := range exts {
+		if strings.HasSuffix(name, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports the first malformed pattern.
+func (f Filter) Validate() error {
+	_, err := f.compile()
+	return err
+}
+
+// compiledFilter is a Filter with parsed patterns.
+type compiledFilter struct {
+	exts    []string
+	include []ignoreRule
+	exclude []ignoreRule
+}
+
+func (f Filter) compile() (*compiledFilter, error) {
+	c := compiledFilter{exts: NormalizeExtensions(f.Extensions)}
+	for _, p := range f.Include {
+		r, ok, err := parseRule("", p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			c.include = append(c.include, r)
+		}
+	}
+	for _, p := range f.Exclude {
+		r, ok, err := parseRule("", p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			c.exclude = append(c.exclude, r)
+		}
+	}
+	return &c, nil
+}
+
+// skip reports whether a directory entry is left out of the scan. The
+// configured excludes always win; .gitignore rules may re-include with "!".
+func (c *compiledFilter) skip(rel string, isDir bool, gitignore []ignoreRule) bool {
+	if !isDir && !HasExtension(rel, c.exts) {
+		return true
+	}
+	for _, r := range c.exclude {
+		if r.match(rel, isDir) {
+			return true
+		}
+	}
+	if ignored(gitignore, rel, isDir) {
+		return true
+	}
+	if isDir || len(c.include) == 0 {
+		return false
+	}
+	for _, r := range c.include {
+		if r.match(rel, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// ignoreRule is one parsed pattern.
+type ignoreRule struct {
+	base     string   // directory the pattern is relative to, "" for the root
+	segments []string // pattern split at "/"
+	anchored bool     // match the path below base rather than the name
+	dirOnly  bool     // trailing "/": match directories only
+	negate   bool     // leading "!": re-include a match
+}
+
+// parseRule parses a pattern. ok is false for blank lines and comments.
+func parseRule(base, pattern string) (r ignoreRule, ok bool, err error) {
+	p := strings.TrimSpace(pattern)
+	if p == "" || strings.HasPrefix(p, "#") {
+		return r, false, nil
+	}
+	r.base = base
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if strings.HasPrefix(p, "/") {
+		r.anchored = true
+		p = strings.TrimLeft(p, "/")
+	}
+	if p == "" {
+		return r, false, nil
+	}
+	if strings.Contains(p, "/") {
+		r.anchored = true
+	}
+	r.segments = strings.Split(p, "/")
+	for _, s := range r.segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return r, false, fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return r, true, nil
+}
+
+// match reports whether the pattern matches a path relative to the root.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// stands for any number of segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ignored applies .gitignore rules in order; the last matching rule wins.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, r := range rules {
+		if r.match(rel, isDir) {
+			result = !r.negate
+		}
+	}
+	return result
+}
+
+// readGitignore appends the rules of relDir/.gitignore, if there is one, to
+// the rules inherited from the parent directories. Malformed lines are
+// skipped like git does.
+func readGitignore(root, relDir string, inherited []ignoreRule) []ignoreRule {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(relDir), ".gitignore"))
+	if err != nil {
+		return inherited
+	}
+	defer f.Close()
+
+	// Subdirectories share the parent's rules; never append in place
+	rules := slices.Clip(inherited)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok, err := parseRule(relDir, sc.Text()); ok && err == nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
@@ -1,172 +1,159 @@
 package scanner
 
 import (
-	"bufio"
-	"os"
+	"context"
 	"path/filepath"
-	"regexp"
 	"strings"
+
+	"php-dep-extractor/internal/framework"
+	"php-dep-extractor/internal/progress"
+	"php-dep-extractor/internal/workers"
 )
 
-// Framework represents the PHP framework type.
+// Framework is the name of a registered framework.
 type Framework string
 
 const (
-	FrameworkZF1     Framework = "zf1"
-	FrameworkCakePHP Framework = "cakephp"
-	FrameworkLaravel Framework = "laravel"
+	FrameworkZF1         Framework = "zf1"
+	FrameworkCakePHP     Framework = "cakephp"
+	FrameworkLaravel     Framework = "laravel"
+	FrameworkSymfony     Framework = "symfony"
+	FrameworkWordPress   Framework = "wordpress"
+	FrameworkCodeIgniter Framework = "codeigniter"
+	FrameworkYii2        Framework = "yii2"
 )
 
 // PrefixMapping maps a class prefix to a directory (for ZF1 style).
-type PrefixMapping struct {
-	Prefix string `json:"prefix"`
-	Dir    string `json:"dir"`
-}
+type PrefixMapping = framework.PrefixMapping
+
+// Index sources reported in ClassIndex.Source.
+const (
+	IndexSourceComposer  = "composer"
+	IndexSourceFramework = "framework"
+)
 
-// ClassIndex maps class names to their relative file paths.
+// ClassIndex maps class and function names to their relative file paths.
 type ClassIndex struct {
-	ClassToFile map[string]string // className -> relative path
-	FileToClass map[string]string // relative path -> className
+	ClassToFile   map[string]string   // className -> relative path
+	FuncToFile    map[string]string   // global function name -> relative path
+	FileSymbols   map[string][]Symbol // relative path -> declared symbols
+	AutoloadFiles []string            // composer "files" entries, always loaded
+	Source        string              // IndexSourceComposer or IndexSourceFramework
+
+	// Conventions the index was built with; Framework is nil for an
+	// unknown framework name
+	Framework framework.Framework
+	Mappings  []PrefixMapping
+
+	// What the framework read from the project's configuration
+	Project framework.Project
 }
 
 // DefaultZF1Mappings returns the default ZF1 prefix→directory mappings.
 func DefaultZF1Mappings() []PrefixMapping {
-	return []PrefixMapping{
-		{Prefix: "Parent_", Dir: "parents/"},
-		{Prefix: "DbTable_", Dir: "dbs/"},
-		{Prefix: "Service_", Dir: "services/"},
-		{Prefix: "Model_", Dir: "models/"},
-		{Prefix: "Form_", Dir: "forms/"},
-	}
+	return framework.DefaultZF1Mappings()
 }
 
 // BuildIndex creates a class name index from scanned files.
+// When the project has a composer.json with autoload rules those rules are
+// used; the framework path conventions are only a fallback.
 func BuildIndex(result *ScanResult, fw Framework, mappings []PrefixMapping) *ClassIndex {
+	// Without a deadline or cancel the context can't end early
+	idx, _ := BuildIndexWith(context.Background(), result, fw, mappings, nil)
+	return idx
+}
+
+// BuildIndexWith is BuildIndex with the declared symbols of some files
+// already known, e.g. from a cache. Files missing from known are parsed.
+// Cancelling ctx stops parsing with ctx.Err().
+func BuildIndexWith(ctx context.Context, result *ScanResult, fw Framework, mappings []PrefixMapping, known map[string][]Symbol) (*ClassIndex, error) {
+	conv := framework.Lookup(string(fw))
 	idx := &ClassIndex{
 		ClassToFile: make(map[string]string),
-		FileToClass: make(map[string]string),
+		FuncToFile:  make(map[string]string),
+		FileSymbols: make(map[string][]Symbol),
+		Source:      IndexSourceFramework,
+		Framework:   conv,
+		Mappings:    mappings,
 	}
 
-	for _, relPath := range result.Files {
-		var className string
-		switch fw {
-		case FrameworkZF1:
-			className = zf1ClassFromPath(relPath, mappings)
-		case FrameworkCakePHP:
-			className = cakeClassFromPath(relPath)
-		case FrameworkLaravel:
-			className = laravelClassFromPath(relPath)
-		}
-
-		if className == "" {
-			// Fallback: read file header to find class declaration
-			className = classFromFileContent(filepath.Join(result.Root, filepath.FromSlash(relPath)))
-		}
-
-		if className != "" {
-			idx.ClassToFile[className] = relPath
-			idx.FileToClass[relPath] = className
-		}
+	// A broken composer.json is treated like a missing one
+	autoload, _ := LoadComposer(result.Root)
+	if autoload != nil && autoload.HasRules() {
+		idx.Source = IndexSourceComposer
+		idx.AutoloadFiles = autoload.Files
 	}
 
-	return idx
-}
-
-// zf1ClassFromPath derives class name from ZF1 path conventions.
-// e.g. "application/models/Car/CarrierCust.php" -> "Model_Car_CarrierCust"
-func zf1ClassFromPath(relPath string, mappings []PrefixMapping) string {
-	// Normalize path
-	p := strings.TrimSuffix(relPath, ".php")
-
-	// Try to strip "application/" prefix
-	appPath := p
-	if strings.HasPrefix(p, "application/") {
-		appPath = strings.TrimPrefix(p, "application/")
-	} else {
-		// Not under application/, skip ZF1 mapping
-		return ""
+	// Parse the files that aren't known yet in parallel
+	parsed := make([][]Symbol, len(result.Files))
+	counter := progress.Start(ctx, progress.PhaseIndex, len(result.Files))
+	err := workers.ForEach(ctx, len(result.Files), func(i int) {
+		if _, ok := known[result.Files[i]]; !ok {
+			parsed[i] = declaredSymbols(filepath.Join(result.Root, filepath.FromSlash(result.Files[i])))
+		}
+		counter.Done(result.Files[i])
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Check each mapping (order matters - longer/more specific prefixes first)
-	for _, m := range mappings {
-		dir := strings.TrimSuffix(m.Dir, "/")
-		if strings.HasPrefix(appPath, dir+"/") {
-			rest := strings.TrimPrefix(appPath, dir+"/")
-			// Convert path separators to underscores
-			className := m.Prefix + strings.ReplaceAll(rest, "/", "_")
-			return className
+	for i, relPath := range result.Files {
+		var className string
+		switch {
+		case !strings.HasSuffix(relPath, ".php"):
+			// Path conventions only cover class files, not views or includes
+		case idx.Source == IndexSourceComposer:
+			className = autoload.ClassFromPath(relPath)
+		case conv != nil:
+			className = conv.ClassFromPath(relPath, mappings)
 		}
-	}
 
-	// Controllers: application/controllers/V3/CustomersController.php
-	if strings.HasPrefix(appPath, "controllers/") {
-		return ""
-	}
+		symbols, ok := known[relPath]
+		if !ok {
+			symbols = parsed[i]
+		}
 
-	return ""
-}
+		// Keep the convention-derived name even if the file doesn't declare it
+		// literally, e.g. when the declaration can't be parsed
+		if className != "" && !hasSymbol(symbols, className) {
+			kind := KindClass
+			for _, sym := range symbols {
+				if sym.Kind != KindFunction {
+					kind = sym.Kind
+					break
+				}
+			}
+			symbols = append([]Symbol{{Name: className, Kind: kind}}, symbols...)
+		}
 
-// cakeClassFromPath derives class name from CakePHP path conventions.
-func cakeClassFromPath(relPath string) string {
-	p := strings.TrimSuffix(relPath, ".php")
-
-	// CakePHP 2.x: app/Model/Post.php -> Post
-	prefixes := []string{"app/", "src/"}
-	for _, prefix := range prefixes {
-		if strings.HasPrefix(p, prefix) {
-			rest := strings.TrimPrefix(p, prefix)
-			// The class name is the filename (last component)
-			parts := strings.Split(rest, "/")
-			if len(parts) > 0 {
-				return parts[len(parts)-1]
+		for _, sym := range symbols {
+			if sym.Kind == KindFunction {
+				idx.FuncToFile[sym.Name] = relPath
+			} else {
+				idx.ClassToFile[sym.Name] = relPath
 			}
 		}
+		if len(symbols) > 0 {
+			idx.FileSymbols[relPath] = symbols
+		}
 	}
-	return ""
-}
-
-// laravelClassFromPath derives fully-qualified class name from Laravel PSR-4 conventions.
-func laravelClassFromPath(relPath string) string {
-	p := strings.TrimSuffix(relPath, ".php")
 
-	// app/Models/User.php -> App\Models\User
-	if strings.HasPrefix(p, "app/") {
-		rest := strings.TrimPrefix(p, "app/")
-		return "App\\" + strings.ReplaceAll(rest, "/", "\\")
+	if conv != nil {
+		idx.Project = conv.Project(framework.Index{
+			Root:        result.Root,
+			Files:       result.Files,
+			ClassToFile: idx.ClassToFile,
+			FuncToFile:  idx.FuncToFile,
+		})
 	}
-	return ""
+	return idx, nil
 }
 
-var classRegex = regexp.MustCompile(`(?m)^\s*(?:abstract\s+|final\s+)?class\s+(\w+)`)
-var interfaceRegex = regexp.MustCompile(`(?m)^\s*interface\s+(\w+)`)
-var traitRegex = regexp.MustCompile(`(?m)^\s*trait\s+(\w+)`)
-
-// classFromFileContent reads the first part of a PHP file to find class declaration.
-func classFromFileContent(absPath string) string {
-	f, err := os.Open(absPath)
-	if err != nil {
-		return ""
-	}
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	lineCount := 0
-	for scanner.Scan() {
-		lineCount++
-		if lineCount > 100 {
-			break
-		}
-		line := scanner.Text()
-
-		if m := classRegex.FindStringSubmatch(line); len(m) > 1 {
-			return m[1]
-		}
-		if m := interfaceRegex.FindStringSubmatch(line); len(m) > 1 {
-			return m[1]
-		}
-		if m := traitRegex.FindStringSubmatch(line); len(m) > 1 {
-			return m[1]
+func hasSymbol(symbols []Symbol, name string) bool {
+	for _, s := range symbols {
+		if s.Name == name {
+			return true
 		}
 	}
-	return ""
+	return false
 }
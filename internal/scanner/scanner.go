@@ -1,54 +1,136 @@
 package scanner
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"php-dep-extractor/internal/progress"
+	"php-dep-extractor/internal/workers"
 )
 
 // ScanResult holds all PHP files found in a project directory.
 type ScanResult struct {
-	Files []string // relative paths using forward slashes
-	Root  string   // absolute project root
+	Files      []string            // relative paths using forward slashes
+	Root       string              // absolute project root
+	Stats      map[string]FileStat // relative path -> size and modification time
+	Extensions []string            // normalized extensions the files were collected by
 }
 
-// ExcludeDirs are directories to skip during scanning.
-var ExcludeDirs = map[string]bool{
-	"vendor":       true,
-	"node_modules": true,
-	".git":         true,
-	".svn":         true,
-	".idea":        true,
+// FileStat is what a rescan compares to decide whether a file changed.
+type FileStat struct {
+	Size    int64
+	ModTime int64 // Unix nanoseconds
 }
 
-// Scan walks the project directory and collects all .php file paths.
-func Scan(root string) (*ScanResult, error) {
+// Scan walks the project directory and collects the PHP file paths the
+// filter lets through. Directories are read level by level on a bounded
+// worker pool; the result is sorted into the order of a sequential
+// depth-first walk. Progress is reported per directory; cancelling ctx
+// stops the walk with ctx.Err().
+func Scan(ctx context.Context, root string, filter Filter) (*ScanResult, error) {
 	root, err := filepath.Abs(root)
 	if err != nil {
 		return nil, err
 	}
+	f, err := filter.compile()
+	if err != nil {
+		return nil, err
+	}
 
 	var files []string
-	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	stats := make(map[string]FileStat)
+
+	level := []dirTask{{}} // the root
+	counter := progress.Start(ctx, progress.PhaseScan, len(level))
+	for len(level) > 0 {
+		results := make([]dirListing, len(level))
+		err := workers.ForEach(ctx, len(level), func(i int) {
+			results[i] = readDir(root, level[i], f)
+			counter.Done(level[i].rel)
+		})
 		if err != nil {
-			return nil
+			return nil, err
 		}
-		if info.IsDir() {
-			name := info.Name()
-			if ExcludeDirs[name] {
-				return filepath.SkipDir
+
+		level = nil
+		for _, r := range results {
+			level = append(level, r.subdirs...)
+			for j, f := range r.files {
+				files = append(files, f)
+				stats[f] = r.stats[j]
 			}
-			return nil
-		}
-		if strings.HasSuffix(strings.ToLower(info.Name()), ".php") {
-			rel, _ := filepath.Rel(root, path)
-			files = append(files, filepath.ToSlash(rel))
 		}
-		return nil
-	})
+		counter.Grow(len(level))
+	}
+
+	sort.Slice(files, func(i, j int) bool { return walkLess(files[i], files[j]) })
+	exts := f.exts
+	if len(exts) == 0 {
+		exts = []string{".php"}
+	}
+	return &ScanResult{Files: files, Root: root, Stats: stats, Extensions: exts}, nil
+}
+
+// dirTask is a directory waiting to be read.
+type dirTask struct {
+	rel       string       // relative path, "" for the root
+	gitignore []ignoreRule // .gitignore rules of the directory and its parents
+}
+
+// dirListing is the scan result of one directory.
+type dirListing struct {
+	subdirs []dirTask
+	files   []string
+	stats   []FileStat
+}
+
+// readDir lists one directory below root. Unreadable directories and
+// files are skipped.
+func readDir(root string, dir dirTask, f *compiledFilter) dirListing {
+	var r dirListing
+	relDir := dir.rel
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(relDir)))
 	if err != nil {
-		return nil, err
+		return r
+	}
+	gitignore := readGitignore(root, relDir, dir.gitignore)
+
+	for _, e := range entries {
+		rel := e.Name()
+		if relDir != "" {
+			rel = relDir + "/" + rel
+		}
+		if e.IsDir() {
+			if !f.skip(rel, true, gitignore) {
+				r.subdirs = append(r.subdirs, dirTask{rel: rel, gitignore: gitignore})
+			}
+			continue
+		}
+		if f.skip(rel, false, gitignore) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		r.files = append(r.files, rel)
+		r.stats = append(r.stats, FileStat{Size: info.Size(), ModTime: info.ModTime().UnixNano()})
 	}
+	return r
+}
 
-	return &ScanResult{Files: files, Root: root}, nil
+// walkLess orders relative paths like a depth-first walk that visits
+// directory entries by name: "a/b.php" sorts before "a.php".
+func walkLess(a, b string) bool {
+	for {
+		aName, aRest, aDir := strings.Cut(a, "/")
+		bName, bRest, bDir := strings.Cut(b, "/")
+		if aName != bName || !aDir || !bDir {
+			return aName < bName
+		}
+		a, b = aRest, bRest
+	}
 }
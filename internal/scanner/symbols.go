@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"os"
+	"strings"
+
+	"php-dep-extractor/internal/lexer"
+)
+
+// Symbol kinds reported in Symbol.Kind.
+const (
+	KindClass     = "class"
+	KindInterface = "interface"
+	KindTrait     = "trait"
+	KindEnum      = "enum"
+	KindFunction  = "function" // global function, not a method
+)
+
+// Symbol is a class-like or function declaration found in a PHP file.
+type Symbol struct {
+	Name string `json:"name"` // fully-qualified, without leading backslash
+	Kind string `json:"kind"`
+}
+
+// declaredSymbols returns the symbols declared in a file, or nil if it
+// can't be read.
+func declaredSymbols(absPath string) []Symbol {
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil
+	}
+	return DeclaredSymbols(string(data))
+}
+
+// DeclaredSymbols returns every class, interface, trait, enum and function
+// declared in PHP source, qualified with the namespace in effect at the
+// declaration. Methods and closures aren't functions.
+func DeclaredSymbols(src string) []Symbol {
+	toks := lexer.Significant(lexer.Tokenize(src))
+
+	var symbols []Symbol
+	namespace := ""
+	nsDepth := -1 // brace depth of a braced namespace block
+	braceDepth := 0
+	var classBodies []int // brace depths of the enclosing class bodies
+	bodyPending := false  // a class declaration waits for its body
+
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		var prev lexer.Token
+		if i > 0 {
+			prev = toks[i-1]
+		}
+
+		switch {
+		case tok.Is("{"):
+			braceDepth++
+			if bodyPending {
+				classBodies = append(classBodies, braceDepth)
+				bodyPending = false
+			}
+		case tok.Is("}"):
+			if n := len(classBodies); n > 0 && classBodies[n-1] == braceDepth {
+				classBodies = classBodies[:n-1]
+			}
+			braceDepth--
+			if braceDepth == nsDepth {
+				namespace, nsDepth = "", -1
+			}
+
+		case tok.Is("namespace") && i+1 < len(toks) && !toks[i+1].Is("("):
+			namespace = ""
+			if toks[i+1].IsName() {
+				namespace = strings.TrimPrefix(toks[i+1].Text, "\\")
+			}
+			if j := i + 1; j < len(toks) && (toks[j].Is("{") || (j+1 < len(toks) && toks[j+1].Is("{"))) {
+				nsDepth = braceDepth
+			}
+
+		case tok.Is(KindClass) || tok.Is(KindInterface) || tok.Is(KindTrait) || tok.Is(KindEnum):
+			// Skip Foo::class, $obj->class and the like
+			if prev.Is("::") || prev.Is("->") || prev.Is("?->") {
+				continue
+			}
+			// An anonymous class has no name but its methods aren't functions
+			if prev.Is("new") {
+				bodyPending = true
+				continue
+			}
+			if i+1 >= len(toks) || toks[i+1].Kind != lexer.Ident {
+				continue
+			}
+			// "enum" is a soft keyword; a declaration is followed by a body or backing type
+			if tok.Is(KindEnum) && (i+2 >= len(toks) || !(toks[i+2].Is("{") || toks[i+2].Is(":") || toks[i+2].Is("implements"))) {
+				continue
+			}
+			name := toks[i+1].Text
+			if namespace != "" {
+				name = namespace + "\\" + name
+			}
+			symbols = append(symbols, Symbol{Name: name, Kind: strings.ToLower(tok.Text)})
+			bodyPending = true
+			i++
+
+		// A function directly in a class body is a method; "use function" imports one
+		case tok.Is(KindFunction) && !prev.Is("use"):
+			j := i + 1
+			if j < len(toks) && toks[j].Is("&") {
+				j++
+			}
+			if j+1 >= len(toks) || toks[j].Kind != lexer.Ident || !toks[j+1].Is("(") {
+				continue
+			}
+			if n := len(classBodies); n > 0 && classBodies[n-1] == braceDepth {
+				continue
+			}
+			name := toks[j].Text
+			if namespace != "" {
+				name = namespace + "\\" + name
+			}
+			symbols = append(symbols, Symbol{Name: name, Kind: KindFunction})
+			i = j
+		}
+	}
+
+	return symbols
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"php-dep-extractor/internal/progress"
+)
+
+// progressInterval limits how often progress events of one phase are sent.
+const progressInterval = 100 * time.Millisecond
+
+// eventStream sends Server-Sent Events to a client that asked for them with
+// "Accept: text/event-stream". A stream carries "progress" events and ends
+// with one "result" or "error" event holding the usual JSON response.
+type eventStream struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	lastPhase string
+	lastSent  time.Time
+}
+
+// startEvents switches the response to an event stream if the client asked
+// for one, and returns a context that reports progress to it. Without a
+// stream it returns nil and the request context.
+func startEvents(w http.ResponseWriter, r *http.Request) (*eventStream, context.Context) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return nil, r.Context()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := &eventStream{w: w, flusher: flusher}
+	return s, progress.WithFunc(r.Context(), s.progress)
+}
+
+// progress sends a progress event. Within a phase, events arriving faster
+// than progressInterval are dropped, except the one completing the phase.
+func (s *eventStream) progress(e progress.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e.Phase == s.lastPhase && e.Done < e.Total && now.Sub(s.lastSent) < progressInterval {
+		return
+	}
+	s.lastPhase, s.lastSent = e.Phase, now
+	s.write("progress", e)
+}
+
+// write must be called with mu held.
+func (s *eventStream) write(event string, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
+
+// reply sends the response of a request that may be streaming events.
+func reply(w http.ResponseWriter, events *eventStream, v any) {
+	if events == nil {
+		writeJSON(w, v)
+		return
+	}
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	events.write("result", v)
+}
+
+// replyError sends an error to a request that may be streaming events. Once
+// streaming the status code has already been sent, so only msg is reported.
+func replyError(w http.ResponseWriter, events *eventStream, code int, msg string) {
+	if events == nil {
+		writeError(w, code, msg)
+		return
+	}
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	events.write("error", map[string]string{"error": msg})
+}
@@ -7,8 +7,12 @@ import (
 	"path/filepath"
 	"strings"
 
+	"php-dep-extractor/internal/cache"
+	"php-dep-extractor/internal/config"
 	"php-dep-extractor/internal/copier"
 	"php-dep-extractor/internal/filetree"
+	"php-dep-extractor/internal/framework"
+	"php-dep-extractor/internal/graph"
 	"php-dep-extractor/internal/parser"
 	"php-dep-extractor/internal/scanner"
 )
@@ -52,12 +56,17 @@ func handleBrowse(state *AppState) http.HandlerFunc {
 	}
 }
 
-// handleScan scans a project directory and returns the file tree.
+// handleScan scans a project directory and returns the file tree. Settings
+// in the project's config file win over the request and the session.
+// Clients accepting text/event-stream receive progress events first.
 func handleScan(state *AppState) http.HandlerFunc {
 	type scanRequest struct {
-		Path      string                  `json:"path"`
-		Framework string                  `json:"framework"`
-		Mappings  []scanner.PrefixMapping `json:"mappings,omitempty"`
+		Path       string                  `json:"path"`
+		Framework  string                  `json:"framework"`
+		Mappings   []scanner.PrefixMapping `json:"mappings,omitempty"`
+		Extensions []string                `json:"extensions"` // nil = use the settings
+		Include    []string                `json:"include"`    // nil = use the settings
+		Exclude    []string                `json:"exclude"`    // nil = use the settings
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -66,6 +75,11 @@ func handleScan(state *AppState) http.HandlerFunc {
 			return
 		}
 
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
 		var req scanRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, 400, "Invalid JSON")
@@ -80,49 +94,146 @@ func handleScan(state *AppState) http.HandlerFunc {
 		// Convert forward slashes back for OS operations
 		osPath := filepath.FromSlash(req.Path)
 
-		result, err := scanner.Scan(osPath)
+		// A project config file wins over the request and the settings
+		cfg, err := config.Load(osPath)
 		if err != nil {
-			writeError(w, 500, "Scan failed: "+err.Error())
+			writeError(w, 400, err.Error())
 			return
 		}
 
-		// Set framework
 		fw := scanner.Framework(req.Framework)
+		if cfg != nil && cfg.Framework != "" {
+			fw = cfg.Framework
+		}
 		if fw == "" {
 			fw = scanner.FrameworkZF1
 		}
+		if framework.Lookup(string(fw)) == nil {
+			writeError(w, 400, "Unknown framework: "+string(fw))
+			return
+		}
+
+		// Patterns sent with the scan replace those saved in the settings
+		sess.mu.RLock()
+		filter := sess.Filter
+		sess.mu.RUnlock()
+		if req.Extensions != nil {
+			filter.Extensions = req.Extensions
+		}
+		if req.Include != nil {
+			filter.Include = req.Include
+		}
+		if req.Exclude != nil {
+			filter.Exclude = req.Exclude
+		}
+		if cfg != nil {
+			filter = cfg.ApplyFilter(filter)
+		}
+		if err := filter.Validate(); err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+
+		events, ctx := startEvents(w, r)
+		ctx, done := sess.startJob(ctx)
+		defer done()
+
+		// Without configured extensions the framework decides
+		scanFilter := filter
+		if len(scanner.NormalizeExtensions(scanFilter.Extensions)) == 0 {
+			scanFilter.Extensions = scanner.DefaultExtensions(fw)
+		}
+		result, err := scanner.Scan(ctx, osPath, scanFilter)
+		if err != nil {
+			replyFailure(w, events, "Scan failed: ", err)
+			return
+		}
 
+		// Mappings sent with the scan win over those saved in the settings
 		mappings := req.Mappings
+		if len(mappings) == 0 {
+			sess.mu.RLock()
+			mappings = sess.Mappings
+			sess.mu.RUnlock()
+		}
+		if cfg != nil && cfg.Mappings != nil {
+			mappings = cfg.Mappings
+		}
 		if len(mappings) == 0 {
 			mappings = scanner.DefaultZF1Mappings()
 		}
 
-		// Build class index
-		index := scanner.BuildIndex(result, fw, mappings)
+		// Reuse the parse results of files unchanged since the last scan
+		c := cache.Load(result.Root)
+		stats, err := c.Refresh(ctx, result)
+
+		// A cache that can't be written only costs time on the next scan.
+		// Files parsed before a cancellation are kept.
+		c.Save()
+		if err != nil {
+			replyFailure(w, events, "Scan failed: ", err)
+			return
+		}
 
-		// Update state
-		state.ProjectRoot = result.Root
-		state.ScanResult = result
-		state.ClassIndex = index
-		state.Framework = fw
-		state.Mappings = mappings
+		// Build class and reference indexes
+		index, err := scanner.BuildIndexWith(ctx, result, fw, mappings, c.Symbols())
+		if err != nil {
+			replyFailure(w, events, "Scan failed: ", err)
+			return
+		}
+		refs, err := parser.BuildReferenceIndex(ctx, result.Files, index, filepath.ToSlash(result.Root), c.Refs())
+		if err != nil {
+			replyFailure(w, events, "Scan failed: ", err)
+			return
+		}
+
+		// Update the session only once the scan is complete
+		sess.mu.Lock()
+		sess.ProjectRoot = result.Root
+		sess.ScanResult = result
+		sess.ClassIndex = index
+		sess.Framework = fw
+		sess.Mappings = mappings
+		sess.Filter = filter
+		if cfg != nil && cfg.Export != nil {
+			sess.Export = cfg.Export
+		}
+		sess.References = refs
+		sess.LastSelected = nil
+		sess.LastResult = nil
+		sess.mu.Unlock()
 
 		// Build file tree
 		tree := filetree.Build(result.Files)
 
-		writeJSON(w, map[string]any{
-			"tree":      tree,
-			"fileCount": len(result.Files),
-			"indexed":   len(index.ClassToFile),
+		reply(w, events, map[string]any{
+			"tree":        tree,
+			"fileCount":   len(result.Files),
+			"indexed":     len(index.ClassToFile),
+			"functions":   len(index.FuncToFile),
+			"indexSource": index.Source,
+			"warnings":    index.Project.Warnings, // configuration files the framework couldn't read
+			"cache":       stats,
+			"config":      cfg, // null without a project config file
 		})
 	}
 }
 
 // handleAnalyze analyzes selected files for dependencies.
+// Clients accepting text/event-stream receive progress events first.
 func handleAnalyze(state *AppState) http.HandlerFunc {
 	type analyzeRequest struct {
 		Files         []string `json:"files"`
 		ParseIncludes bool     `json:"parseIncludes"`
+		Transitive    bool     `json:"transitive"`
+		MaxDepth      int      `json:"maxDepth"`
+		Budget        int      `json:"budget"` // token budget; 0 = no budget
+		Stubs         bool     `json:"stubs"`  // stub distant files before dropping them
+	}
+
+	type analyzeResponse struct {
+		*parser.DependencyResult
+		Tokens *parser.TokenReport `json:"tokens"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -131,37 +242,136 @@ func handleAnalyze(state *AppState) http.HandlerFunc {
 			return
 		}
 
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
 		var req analyzeRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, 400, "Invalid JSON")
 			return
 		}
 
-		if state.ClassIndex == nil {
+		projectRoot, index, _ := sess.project()
+		if index == nil {
 			writeError(w, 400, "Project not scanned yet")
 			return
 		}
+		sess.mu.RLock()
+		extensions := sess.ScanResult.Extensions
+		sess.mu.RUnlock()
 
 		if len(req.Files) == 0 {
 			writeError(w, 400, "No files selected")
 			return
 		}
 
-		result, err := parser.Resolve(req.Files, state.ClassIndex, filepath.ToSlash(state.ProjectRoot), req.ParseIncludes)
+		if req.MaxDepth < 0 || req.Budget < 0 {
+			writeError(w, 400, "maxDepth and budget must not be negative")
+			return
+		}
+
+		opts := parser.ResolveOptions{
+			ParseIncludes: req.ParseIncludes,
+			Transitive:    req.Transitive,
+			MaxDepth:      req.MaxDepth,
+			Extensions:    extensions,
+		}
+
+		events, ctx := startEvents(w, r)
+		ctx, done := sess.startJob(ctx)
+		defer done()
+
+		root := filepath.ToSlash(projectRoot)
+		result, err := parser.Resolve(ctx, req.Files, index, root, opts)
 		if err != nil {
-			writeError(w, 500, "Analysis failed: "+err.Error())
+			replyFailure(w, events, "Analysis failed: ", err)
 			return
 		}
 
-		writeJSON(w, result)
+		sess.mu.Lock()
+		sess.LastSelected = req.Files
+		sess.LastResult = result
+		sess.mu.Unlock()
+
+		reply(w, events, analyzeResponse{
+			DependencyResult: result,
+			Tokens:           parser.EstimateTokens(req.Files, result, nil, root, req.Budget, req.Stubs),
+		})
 	}
 }
 
-// handleCopy copies files to the output directory.
+// handleEstimate re-estimates the tokens of the last analysis with the
+// include targets that are exported along with it, so that ticked includes
+// count towards the budget like they do on the command line.
+func handleEstimate(state *AppState) http.HandlerFunc {
+	type estimateRequest struct {
+		Includes []string `json:"includes"` // resolved include targets to export
+		Budget   int      `json:"budget"`
+		Stubs    bool     `json:"stubs"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "Method not allowed")
+			return
+		}
+
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
+		var req estimateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "Invalid JSON")
+			return
+		}
+		if req.Budget < 0 {
+			writeError(w, 400, "budget must not be negative")
+			return
+		}
+
+		projectRoot, _, _ := sess.project()
+		sess.mu.RLock()
+		selected, result := sess.LastSelected, sess.LastResult
+		sess.mu.RUnlock()
+		if result == nil {
+			writeError(w, 400, "No analysis yet")
+			return
+		}
+
+		// Only targets the analysis resolved can be exported.
+		resolved := make(map[string]bool)
+		for _, inc := range result.Includes {
+			if inc.Resolved != "" {
+				resolved[inc.Resolved] = true
+			}
+		}
+		var includes []string
+		for _, p := range req.Includes {
+			if !resolved[p] {
+				writeError(w, 400, "Not an include target of the last analysis: "+p)
+				return
+			}
+			includes = append(includes, p)
+		}
+
+		root := filepath.ToSlash(projectRoot)
+		writeJSON(w, parser.EstimateTokens(selected, result, includes, root, req.Budget, req.Stubs))
+	}
+}
+
+// handleCopy copies files to the output directory. With a bundle or archive
+// format a single file is written into it instead, e.g. "bundle.md" or
+// "bundle.zip". Stubbed files are reduced to signatures in every format.
 func handleCopy(state *AppState) http.HandlerFunc {
 	type copyRequest struct {
 		Files     []string `json:"files"`
 		OutputDir string   `json:"outputDir"`
+		Format    string   `json:"format,omitempty"`  // "", "files", a bundle or an archive format
+		Stubbed   []string `json:"stubbed,omitempty"` // files reduced to signatures
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -170,13 +380,19 @@ func handleCopy(state *AppState) http.HandlerFunc {
 			return
 		}
 
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
 		var req copyRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, 400, "Invalid JSON")
 			return
 		}
 
-		if state.ProjectRoot == "" {
+		projectRoot, _, _ := sess.project()
+		if projectRoot == "" {
 			writeError(w, 400, "Project not scanned yet")
 			return
 		}
@@ -192,24 +408,240 @@ func handleCopy(state *AppState) http.HandlerFunc {
 		}
 
 		osOutput := filepath.FromSlash(req.OutputDir)
-		result := copier.CopyFiles(req.Files, state.ProjectRoot, osOutput)
+		filter := parser.StubFilter(req.Stubbed)
+
+		if req.Format == "" || req.Format == "files" {
+			result := copier.CopyFiles(req.Files, projectRoot, osOutput, filter)
+			writeJSON(w, result)
+			return
+		}
+
+		var outPath string
+		var result *copier.CopyResult
+		var err error
+		if format, ok := copier.ParseBundleFormat(req.Format); ok {
+			outPath = filepath.Join(osOutput, "bundle"+format.Ext())
+			result, err = copier.WriteBundleFile(outPath, req.Files, projectRoot, format, filter)
+		} else if format, ok := copier.ParseArchiveFormat(req.Format); ok {
+			outPath = filepath.Join(osOutput, "bundle"+format.Ext())
+			result, err = copier.WriteArchiveFile(outPath, req.Files, projectRoot, format, filter)
+		} else {
+			writeError(w, 400, "Unknown format: "+req.Format)
+			return
+		}
+		if err != nil {
+			writeError(w, 500, "Export failed: "+err.Error())
+			return
+		}
+
+		writeJSON(w, map[string]any{
+			"copied": result.Copied,
+			"errors": result.Errors,
+			"file":   filepath.ToSlash(outPath),
+		})
+	}
+}
+
+// handleBundle streams the given files as a single bundle document.
+func handleBundle(state *AppState) http.HandlerFunc {
+	type bundleRequest struct {
+		Files   []string `json:"files"`
+		Format  string   `json:"format"`
+		Stubbed []string `json:"stubbed,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "Method not allowed")
+			return
+		}
+
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
+		var req bundleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "Invalid JSON")
+			return
+		}
+
+		projectRoot, _, _ := sess.project()
+		if projectRoot == "" {
+			writeError(w, 400, "Project not scanned yet")
+			return
+		}
+
+		if len(req.Files) == 0 {
+			writeError(w, 400, "No files to bundle")
+			return
+		}
+
+		if req.Format == "" {
+			req.Format = string(copier.BundleMarkdown)
+		}
+		format, ok := copier.ParseBundleFormat(req.Format)
+		if !ok {
+			writeError(w, 400, "Unknown format: "+req.Format)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="bundle`+format.Ext()+`"`)
+
+		// Unreadable files are skipped; the response has already started,
+		// so they can't be reported as an error status
+		copier.WriteBundle(w, req.Files, projectRoot, format, parser.StubFilter(req.Stubbed))
+	}
+}
+
+// handleGraph renders the dependency graph of the last analysis.
+func handleGraph(state *AppState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "Method not allowed")
+			return
+		}
+
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
+		name := r.URL.Query().Get("format")
+		if name == "" {
+			name = string(graph.FormatDOT)
+		}
+		format, ok := graph.ParseFormat(name)
+		if !ok {
+			writeError(w, 400, "Unknown format: "+name)
+			return
+		}
+
+		sess.mu.RLock()
+		selected, result := sess.LastSelected, sess.LastResult
+		sess.mu.RUnlock()
+		if result == nil {
+			writeError(w, 400, "No analysis yet")
+			return
+		}
 
-		writeJSON(w, result)
+		w.Header().Set("Content-Type", format.ContentType())
+		graph.Write(w, graph.Build(selected, result), format)
 	}
 }
 
-// handleSettings returns/updates the current prefix mappings.
+// handleDependents lists the files that reference the given files.
+func handleDependents(state *AppState) http.HandlerFunc {
+	type dependentsRequest struct {
+		Files      []string `json:"files"`
+		Transitive bool     `json:"transitive"`
+		MaxDepth   int      `json:"maxDepth"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "Method not allowed")
+			return
+		}
+
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
+		var req dependentsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "Invalid JSON")
+			return
+		}
+
+		_, _, refs := sess.project()
+		if refs == nil {
+			writeError(w, 400, "Project not scanned yet")
+			return
+		}
+
+		if len(req.Files) == 0 {
+			writeError(w, 400, "No files selected")
+			return
+		}
+
+		if req.MaxDepth < 0 {
+			writeError(w, 400, "maxDepth must not be negative")
+			return
+		}
+
+		dependents := refs.Dependents(req.Files, parser.ResolveOptions{
+			Transitive: req.Transitive,
+			MaxDepth:   req.MaxDepth,
+		})
+		writeJSON(w, map[string]any{"dependents": dependents})
+	}
+}
+
+// handleCancel stops the running scans and analyses of a session.
+func handleCancel(state *AppState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "Method not allowed")
+			return
+		}
+
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+		writeJSON(w, map[string]int{"cancelled": sess.jobs.cancelAll()})
+	}
+}
+
+// frameworkInfos lists the registered frameworks for the UI.
+func frameworkInfos() []map[string]string {
+	var infos []map[string]string
+	for _, fw := range framework.All() {
+		infos = append(infos, map[string]string{"name": fw.Name(), "label": fw.Label()})
+	}
+	return infos
+}
+
+// handleSettings returns/updates the prefix mappings, file extensions,
+// scan patterns, export options and number of parallel workers of a
+// session. Fields missing from a POST are left unchanged; with
+// "saveProject" the session settings are then written to the project's
+// config file.
 func handleSettings(state *AppState) http.HandlerFunc {
 	type settingsRequest struct {
-		Mappings []scanner.PrefixMapping `json:"mappings"`
+		Mappings    []scanner.PrefixMapping `json:"mappings"`
+		Extensions  []string                `json:"extensions"` // empty = framework default
+		Include     []string                `json:"include"`
+		Exclude     []string                `json:"exclude"`
+		Jobs        *int                    `json:"jobs"` // 0 = number of CPUs
+		Export      *config.Export          `json:"export"`
+		SaveProject bool                    `json:"saveProject"` // write the settings to the project config file
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
 		switch r.Method {
 		case http.MethodGet:
+			sess.mu.RLock()
+			defer sess.mu.RUnlock()
 			writeJSON(w, map[string]any{
-				"framework": state.Framework,
-				"mappings":  state.Mappings,
+				"framework":         sess.Framework,
+				"frameworks":        frameworkInfos(),
+				"mappings":          sess.Mappings,
+				"extensions":        sess.Filter.Extensions,
+				"defaultExtensions": scanner.DefaultExtensions(sess.Framework),
+				"include":           sess.Filter.Include,
+				"exclude":           sess.Filter.Exclude,
+				"export":            sess.Export,
+				"jobs":              sess.Jobs,
 			})
 		case http.MethodPost:
 			var req settingsRequest
@@ -217,7 +649,180 @@ func handleSettings(state *AppState) http.HandlerFunc {
 				writeError(w, 400, "Invalid JSON")
 				return
 			}
-			state.Mappings = req.Mappings
+			if req.Jobs != nil && *req.Jobs < 0 {
+				writeError(w, 400, "jobs must not be negative")
+				return
+			}
+			if req.Export != nil && (req.Export.MaxDepth < 0 || req.Export.Budget < 0) {
+				writeError(w, 400, "maxDepth and budget must not be negative")
+				return
+			}
+			filter := scanner.Filter{Include: req.Include, Exclude: req.Exclude}
+			if err := filter.Validate(); err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+
+			sess.mu.Lock()
+			if req.Mappings != nil {
+				sess.Mappings = req.Mappings
+			}
+			if req.Extensions != nil {
+				sess.Filter.Extensions = req.Extensions
+			}
+			if req.Include != nil {
+				sess.Filter.Include = req.Include
+			}
+			if req.Exclude != nil {
+				sess.Filter.Exclude = req.Exclude
+			}
+			if req.Export != nil {
+				sess.Export = req.Export
+			}
+			if req.Jobs != nil {
+				sess.Jobs = *req.Jobs
+			}
+			root := sess.ProjectRoot
+			cfg := &config.Config{
+				Framework:  sess.Framework,
+				Mappings:   sess.Mappings,
+				Extensions: sess.Filter.Extensions,
+				Include:    sess.Filter.Include,
+				Exclude:    sess.Filter.Exclude,
+				Export:     sess.Export,
+			}
+			sess.mu.Unlock()
+
+			if req.SaveProject {
+				if root == "" {
+					writeError(w, 400, "Project not scanned yet")
+					return
+				}
+				// Keep the presets saved in the file
+				old, err := config.Load(root)
+				if err != nil {
+					writeError(w, 400, err.Error())
+					return
+				}
+				if old != nil {
+					cfg.Presets = old.Presets
+				}
+				if err := cfg.Save(root); err != nil {
+					writeError(w, 500, "Could not write "+config.FileName+": "+err.Error())
+					return
+				}
+				writeJSON(w, map[string]string{"status": "ok", "path": filepath.ToSlash(config.Path(root))})
+				return
+			}
+			writeJSON(w, map[string]string{"status": "ok"})
+		default:
+			writeError(w, 405, "Method not allowed")
+		}
+	}
+}
+
+// handlePresets lists (GET), saves (POST) or deletes (DELETE ?name=) the
+// selection presets stored in the project config file. The file is read on
+// every request so presets added by teammates show up without a rescan.
+func handlePresets(state *AppState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess := requestSession(w, r, state)
+		if sess == nil {
+			return
+		}
+
+		root, _, _ := sess.project()
+		if root == "" {
+			writeError(w, 400, "Project not scanned yet")
+			return
+		}
+		cfg, err := config.Load(root)
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+		if cfg == nil {
+			cfg = &config.Config{}
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			presets := cfg.Presets
+			if presets == nil {
+				presets = []config.Preset{}
+			}
+			writeJSON(w, map[string]any{"presets": presets})
+			return
+		case http.MethodPost:
+			var p config.Preset
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				writeError(w, 400, "Invalid JSON")
+				return
+			}
+			p.Name = strings.TrimSpace(p.Name)
+			if p.Name == "" || len(p.Files) == 0 {
+				writeError(w, 400, "Name and files are required")
+				return
+			}
+			if p.Export != nil && (p.Export.MaxDepth < 0 || p.Export.Budget < 0) {
+				writeError(w, 400, "maxDepth and budget must not be negative")
+				return
+			}
+			cfg.SetPreset(p)
+		case http.MethodDelete:
+			if !cfg.RemovePreset(r.URL.Query().Get("name")) {
+				writeError(w, 404, "Unknown preset")
+				return
+			}
+		default:
+			writeError(w, 405, "Method not allowed")
+			return
+		}
+
+		if err := cfg.Save(root); err != nil {
+			writeError(w, 500, "Could not write "+config.FileName+": "+err.Error())
+			return
+		}
+		writeJSON(w, map[string]string{"status": "ok", "path": filepath.ToSlash(config.Path(root))})
+	}
+}
+
+// handleSessions lists sessions (GET), names one (POST) or removes one
+// (DELETE). The session is addressed like in every other request, with the
+// "session" query parameter.
+func handleSessions(state *AppState) http.HandlerFunc {
+	type sessionRequest struct {
+		Name string `json:"name"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, map[string]any{"sessions": state.sessionInfos()})
+		case http.MethodPost:
+			var req sessionRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, 400, "Invalid JSON")
+				return
+			}
+			sess := requestSession(w, r, state)
+			if sess == nil {
+				return
+			}
+			sess.mu.Lock()
+			sess.Name = req.Name
+			sess.mu.Unlock()
+			writeJSON(w, sess.info())
+		case http.MethodDelete:
+			id, err := sessionID(r)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			if !state.removeSession(id) {
+				writeError(w, 404, "Unknown session")
+				return
+			}
 			writeJSON(w, map[string]string{"status": "ok"})
 		default:
 			writeError(w, 405, "Method not allowed")
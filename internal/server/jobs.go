@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errCancelled is the error message of a job stopped by /api/cancel or by
+// the client going away.
+const errCancelled = "Cancelled"
+
+// jobTracker remembers the cancel functions of running scans and analyses
+// so /api/cancel can stop them.
+type jobTracker struct {
+	mu      sync.Mutex
+	next    int
+	cancels map[int]context.CancelFunc
+}
+
+// start registers a job. The returned context is cancelled by cancelAll or
+// when the parent ends; done must be called when the job finishes.
+func (t *jobTracker) start(parent context.Context) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancels == nil {
+		t.cancels = make(map[int]context.CancelFunc)
+	}
+	id := t.next
+	t.next++
+	t.cancels[id] = cancel
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.cancels, id)
+		t.mu.Unlock()
+		cancel()
+	}
+}
+
+// running returns the number of running jobs.
+func (t *jobTracker) running() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.cancels)
+}
+
+// cancelAll cancels every running job and returns how many there were.
+func (t *jobTracker) cancelAll() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(t.cancels)
+	for id, cancel := range t.cancels {
+		cancel()
+		delete(t.cancels, id)
+	}
+	return n
+}
+
+// replyFailure reports a failed job. Cancellation is not a server error and
+// gets a fixed message the UI can recognise.
+func replyFailure(w http.ResponseWriter, events *eventStream, prefix string, err error) {
+	if errors.Is(err, context.Canceled) {
+		replyError(w, events, http.StatusConflict, errCancelled)
+		return
+	}
+	replyError(w, events, 500, prefix+err.Error())
+}
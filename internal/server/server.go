@@ -4,25 +4,19 @@ import (
 	"embed"
 	"io/fs"
 	"net/http"
-
-	"php-dep-extractor/internal/scanner"
+	"sync"
 )
 
-// AppState holds the current application state.
+// AppState holds the sessions of the running server. It is safe for
+// concurrent use by the handlers.
 type AppState struct {
-	ProjectRoot string
-	ScanResult  *scanner.ScanResult
-	ClassIndex  *scanner.ClassIndex
-	Framework   scanner.Framework
-	Mappings    []scanner.PrefixMapping
+	mu       sync.Mutex
+	sessions map[string]*Session
 }
 
 // New creates a new HTTP handler with all routes registered.
 func New(webFS embed.FS) http.Handler {
-	state := &AppState{
-		Framework: scanner.FrameworkZF1,
-		Mappings:  scanner.DefaultZF1Mappings(),
-	}
+	state := &AppState{}
 
 	mux := http.NewServeMux()
 
@@ -30,7 +24,14 @@ func New(webFS embed.FS) http.Handler {
 	mux.HandleFunc("/api/browse", handleBrowse(state))
 	mux.HandleFunc("/api/scan", handleScan(state))
 	mux.HandleFunc("/api/analyze", handleAnalyze(state))
+	mux.HandleFunc("/api/estimate", handleEstimate(state))
 	mux.HandleFunc("/api/copy", handleCopy(state))
+	mux.HandleFunc("/api/bundle", handleBundle(state))
+	mux.HandleFunc("/api/graph", handleGraph(state))
+	mux.HandleFunc("/api/dependents", handleDependents(state))
+	mux.HandleFunc("/api/cancel", handleCancel(state))
+	mux.HandleFunc("/api/presets", handlePresets(state))
+	mux.HandleFunc("/api/sessions", handleSessions(state))
 	mux.HandleFunc("/api/settings", handleSettings(state))
 
 	// Serve embedded web files
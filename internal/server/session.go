@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"php-dep-extractor/internal/config"
+	"php-dep-extractor/internal/parser"
+	"php-dep-extractor/internal/scanner"
+	"php-dep-extractor/internal/workers"
+)
+
+// defaultSessionID is used by requests that don't name a session, so
+// single-project clients keep working without knowing about sessions.
+const defaultSessionID = "default"
+
+// maxSessionIDLen bounds client-chosen session IDs.
+const maxSessionIDLen = 64
+
+// Sessions are removed after sessionIdleTimeout without a request, and
+// the least recently used one makes room when maxSessions are open.
+// Sessions running a job are kept.
+const (
+	sessionIdleTimeout = 12 * time.Hour
+	maxSessions        = 32
+)
+
+// Session is one project opened on the server. Each browser tab uses its
+// own session, so several codebases can be compared side by side.
+//
+// The scan and analysis results are replaced as a whole, never modified,
+// so they may be used after reading them under the lock.
+type Session struct {
+	ID string
+
+	mu          sync.RWMutex
+	Name        string
+	ProjectRoot string
+	ScanResult  *scanner.ScanResult
+	ClassIndex  *scanner.ClassIndex
+	Framework   scanner.Framework
+	Mappings    []scanner.PrefixMapping
+	Filter      scanner.Filter         // include and exclude patterns for scans
+	Export      *config.Export         // analysis and export options of the project config, if any
+	References  *parser.ReferenceIndex // who references each file, built on scan
+	Jobs        int                    // files read and parsed in parallel; 0 = number of CPUs
+
+	// Last analysis, used by /api/graph
+	LastSelected []string
+	LastResult   *parser.DependencyResult
+
+	jobs     jobTracker // running scans and analyses, for /api/cancel
+	lastUsed time.Time  // guarded by AppState.mu
+}
+
+// SessionInfo summarizes a session for /api/sessions.
+type SessionInfo struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	Root      string            `json:"root,omitempty"`
+	Framework scanner.Framework `json:"framework"`
+	FileCount int               `json:"fileCount"`
+}
+
+func newSession(id string) *Session {
+	return &Session{
+		ID:        id,
+		Framework: scanner.FrameworkZF1,
+		Mappings:  scanner.DefaultZF1Mappings(),
+		Filter:    scanner.DefaultFilter(),
+	}
+}
+
+// project returns the scanned project root and indexes. index is nil
+// before the first scan.
+func (s *Session) project() (root string, index *scanner.ClassIndex, refs *parser.ReferenceIndex) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ProjectRoot, s.ClassIndex, s.References
+}
+
+// startJob registers a scan or analysis with the session's job tracker
+// and worker limit. done must be called when the job finishes.
+func (s *Session) startJob(parent context.Context) (ctx context.Context, done func()) {
+	s.mu.RLock()
+	jobs := s.Jobs
+	s.mu.RUnlock()
+	ctx, done = s.jobs.start(parent)
+	return workers.WithLimit(ctx, jobs), done
+}
+
+// info returns a summary of the session.
+func (s *Session) info() SessionInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info := SessionInfo{ID: s.ID, Name: s.Name, Root: s.ProjectRoot, Framework: s.Framework}
+	if s.ScanResult != nil {
+		info.FileCount = len(s.ScanResult.Files)
+	}
+	return info
+}
+
+// session returns the session with the given ID, creating it on first use.
+// It fails if maxSessions are open and all of them are running jobs.
+func (a *AppState) session(id string) (*Session, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sessions == nil {
+		a.sessions = make(map[string]*Session)
+	}
+	now := time.Now()
+	a.expireSessions(now)
+
+	s, ok := a.sessions[id]
+	if !ok {
+		if len(a.sessions) >= maxSessions && !a.evictSession() {
+			return nil, fmt.Errorf("too many sessions open (%d)", maxSessions)
+		}
+		s = newSession(id)
+		a.sessions[id] = s
+	}
+	s.lastUsed = now
+	return s, nil
+}
+
+// expireSessions removes the idle sessions that timed out. a.mu must be
+// held.
+func (a *AppState) expireSessions(now time.Time) {
+	for id, s := range a.sessions {
+		if now.Sub(s.lastUsed) > sessionIdleTimeout && s.jobs.running() == 0 {
+			delete(a.sessions, id)
+		}
+	}
+}
+
+// evictSession removes the least recently used session without running
+// jobs and reports whether there was one. a.mu must be held.
+func (a *AppState) evictSession() bool {
+	var oldest *Session
+	for _, s := range a.sessions {
+		if s.jobs.running() == 0 && (oldest == nil || s.lastUsed.Before(oldest.lastUsed)) {
+			oldest = s
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	delete(a.sessions, oldest.ID)
+	return true
+}
+
+// removeSession deletes a session and cancels its running jobs.
+func (a *AppState) removeSession(id string) bool {
+	a.mu.Lock()
+	s, ok := a.sessions[id]
+	delete(a.sessions, id)
+	a.mu.Unlock()
+
+	if ok {
+		s.jobs.cancelAll()
+	}
+	return ok
+}
+
+// sessionInfos lists all sessions ordered by ID.
+func (a *AppState) sessionInfos() []SessionInfo {
+	a.mu.Lock()
+	sessions := make([]*Session, 0, len(a.sessions))
+	for _, s := range a.sessions {
+		sessions = append(sessions, s)
+	}
+	a.mu.Unlock()
+
+	infos := make([]SessionInfo, len(sessions))
+	for i, s := range sessions {
+		infos[i] = s.info()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// sessionID reads the "session" query parameter, falling back to the
+// default session.
+func sessionID(r *http.Request) (string, error) {
+	id := r.URL.Query().Get("session")
+	if id == "" {
+		return defaultSessionID, nil
+	}
+	if len(id) > maxSessionIDLen {
+		return "", fmt.Errorf("session ID longer than %d characters", maxSessionIDLen)
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return "", fmt.Errorf("invalid session ID %q", id)
+		}
+	}
+	return id, nil
+}
+
+// requestSession returns the session a request addresses, or writes an
+// error and returns nil.
+func requestSession(w http.ResponseWriter, r *http.Request, state *AppState) *Session {
+	id, err := sessionID(r)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return nil
+	}
+	s, err := state.session(id)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return nil
+	}
+	return s
+}
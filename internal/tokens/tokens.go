@@ -0,0 +1,133 @@
+package tokens
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Estimate approximates the number of tokens a BPE tokenizer produces for text.
+//
+// The heuristic mirrors how BPE vocabularies treat source code: every
+// punctuation or symbol character is roughly one token, runs of letters and
+// digits cost one token per 4 characters (rounded up), whitespace runs are
+// mostly merged into neighbouring tokens and count one per newline, and
+// non-ASCII characters count one each. On typical PHP this lands within about
+// 15% of real tokenizers, which is enough for budgeting.
+func Estimate(text string) int {
+	n := 0
+	word := 0
+	flush := func() {
+		n += (word + 3) / 4
+		word = 0
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			word++
+		case r == '\n':
+			flush()
+			n++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			n++
+		}
+	}
+	flush()
+	return n
+}
+
+// File is a file considered for export together with its token cost.
+type File struct {
+	Path       string `json:"path"`
+	Depth      int    `json:"depth"` // 0 = selected by the user, 1+ = dependency depth
+	Tokens     int    `json:"tokens"`
+	StubTokens int    `json:"stubTokens,omitempty"` // cost when reduced to signatures, 0 if unknown
+}
+
+// Plan is the outcome of fitting files into a token budget.
+type Plan struct {
+	Budget  int      `json:"budget"`
+	Total   int      `json:"total"` // tokens of the kept and stubbed files
+	Fits    bool     `json:"fits"`
+	Keep    []string `json:"keep"`
+	Stubbed []string `json:"stubbed,omitempty"`
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// Fit reduces files until their total token count fits within budget.
+// Files are given up farthest-first: the deepest dependency is stubbed (when
+// allowStubs is set and a stub cost is known) before any file is dropped, and
+// ties are broken by size. Selected files (depth 0) are never removed, so the
+// plan may still exceed the budget.
+func Fit(files []File, budget int, allowStubs bool) *Plan {
+	type entry struct {
+		File
+		stubbed bool
+		dropped bool
+	}
+
+	entries := make([]*entry, len(files))
+	total := 0
+	for i, f := range files {
+		entries[i] = &entry{File: f}
+		total += f.Tokens
+	}
+
+	// Candidates for reduction, farthest and largest first
+	order := make([]*entry, 0, len(entries))
+	for _, e := range entries {
+		if e.Depth > 0 {
+			order = append(order, e)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].Depth != order[j].Depth {
+			return order[i].Depth > order[j].Depth
+		}
+		return order[i].Tokens > order[j].Tokens
+	})
+
+	if allowStubs {
+		for _, e := range order {
+			if total <= budget {
+				break
+			}
+			if e.StubTokens > 0 && e.StubTokens < e.Tokens {
+				e.stubbed = true
+				total -= e.Tokens - e.StubTokens
+			}
+		}
+	}
+	for _, e := range order {
+		if total <= budget {
+			break
+		}
+		cost := e.Tokens
+		if e.stubbed {
+			cost = e.StubTokens
+		}
+		e.dropped = true
+		e.stubbed = false
+		total -= cost
+	}
+
+	plan := &Plan{Budget: budget, Total: total, Fits: total <= budget}
+	for _, e := range entries {
+		switch {
+		case e.dropped:
+			plan.Dropped = append(plan.Dropped, e.Path)
+		case e.stubbed:
+			plan.Stubbed = append(plan.Stubbed, e.Path)
+		default:
+			plan.Keep = append(plan.Keep, e.Path)
+		}
+	}
+	return plan
+}
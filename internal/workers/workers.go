@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// limit is the configured number of workers; 0 means the default.
+var limit atomic.Int64
+
+// SetLimit sets how many files are read and parsed concurrently.
+// n <= 0 restores the default, the number of CPUs.
+func SetLimit(n int) {
+	if n < 0 {
+		n = 0
+	}
+	limit.Store(int64(n))
+}
+
+// Limit returns the number of workers ForEach uses when ctx sets none.
+func Limit() int {
+	if n := int(limit.Load()); n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+type limitKey struct{}
+
+// WithLimit returns a context under which ForEach uses n workers instead
+// of Limit(), e.g. for the jobs of one server session. n <= 0 keeps
+// Limit().
+func WithLimit(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, limitKey{}, n)
+}
+
+// limitOf returns the number of workers ForEach uses under ctx.
+func limitOf(ctx context.Context) int {
+	if n, ok := ctx.Value(limitKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return Limit()
+}
+
+// ForEach calls fn(i) for every i in [0, n) on at most as many goroutines
+// as the limit of ctx and waits for all calls to return. Callers store the result of item i at
+// index i, so output order never depends on scheduling.
+//
+// Once ctx is cancelled no further items are started and ctx.Err() is
+// returned; items already running finish first.
+func ForEach(ctx context.Context, n int, fn func(i int)) error {
+	workers := min(limitOf(ctx), n)
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if ctx.Err() != nil {
+				break
+			}
+			fn(i)
+		}
+		return ctx.Err()
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
@@ -6,9 +6,11 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
 
+	"php-dep-extractor/internal/cli"
 	"php-dep-extractor/internal/server"
 )
 
@@ -18,6 +20,11 @@ var webFS embed.FS
 const Version = "0.1.0"
 
 func main() {
+	// Any arguments select the headless command-line mode
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(Version, os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		log.Fatal("Failed to listen:", err)
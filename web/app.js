@@ -15,6 +15,8 @@ const state = {
     dependencies: [],
     includes: [],
     checkedIncludes: new Set(),
+    presets: [],
+    tokens: null,
     searchFilter: '',
 };
 
@@ -22,8 +24,20 @@ const state = {
 // API helpers
 // ============================================================
 
+// Every tab works in its own server session, so two projects can be open
+// side by side. sessionStorage keeps the ID across reloads of the tab.
+const sessionId = sessionStorage.getItem('pde-session') || (() => {
+    const id = Array.from(crypto.getRandomValues(new Uint8Array(8)), b => b.toString(16).padStart(2, '0')).join('');
+    sessionStorage.setItem('pde-session', id);
+    return id;
+})();
+
+function apiUrl(path) {
+    return path + (path.includes('?') ? '&' : '?') + 'session=' + sessionId;
+}
+
 async function api(path, body) {
-    const resp = await fetch(path, {
+    const resp = await fetch(apiUrl(path), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(body),
@@ -33,6 +47,48 @@ async function api(path, body) {
     return data;
 }
 
+// POST asking for Server-Sent Events: onProgress receives every progress
+// event and the final result event resolves the promise. Requests rejected
+// before streaming starts come back as plain JSON.
+async function apiStream(path, body, onProgress) {
+    const resp = await fetch(apiUrl(path), {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', 'Accept': 'text/event-stream' },
+        body: JSON.stringify(body),
+    });
+    if (!(resp.headers.get('Content-Type') || '').startsWith('text/event-stream')) {
+        const data = await resp.json();
+        if (data.error) throw new Error(data.error);
+        return data;
+    }
+
+    const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buf = '';
+    for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buf += value;
+
+        let end;
+        while ((end = buf.indexOf('\n\n')) >= 0) {
+            const message = buf.slice(0, end);
+            buf = buf.slice(end + 2);
+
+            let event = 'message';
+            let data = '';
+            for (const line of message.split('\n')) {
+                if (line.startsWith('event: ')) event = line.slice(7);
+                else if (line.startsWith('data: ')) data += line.slice(6);
+            }
+            const payload = JSON.parse(data);
+            if (event === 'progress') onProgress(payload);
+            else if (event === 'error') throw new Error(payload.error);
+            else if (event === 'result') return payload;
+        }
+    }
+    throw new Error('Connection closed before the result arrived');
+}
+
 // ============================================================
 // Theme (now inside Settings modal)
 // ============================================================
@@ -94,6 +150,26 @@ $('#fontInc').addEventListener('click', () => {
     applyFontSize(saved);
 })();
 
+// Offer the frameworks the server supports
+(function initFrameworks() {
+    fetch(apiUrl('/api/settings'))
+        .then(r => r.json())
+        .then(data => {
+            if (!data.frameworks) return;
+            const select = $('#framework');
+            const current = select.value;
+            select.innerHTML = '';
+            data.frameworks.forEach(fw => {
+                const opt = document.createElement('option');
+                opt.value = fw.name;
+                opt.textContent = fw.label;
+                select.appendChild(opt);
+            });
+            select.value = current;
+        })
+        .catch(() => {});
+})();
+
 // ============================================================
 // Settings modal with tabs
 // ============================================================
@@ -125,16 +201,21 @@ $$('.tab').forEach(tabBtn => {
     });
 });
 
-// Save mappings
-$('#btnMappingsSave').addEventListener('click', async () => {
-    const rows = $$('.mapping-row');
+// Mappings as edited in the settings
+function currentMappings() {
     const mappings = [];
-    rows.forEach(row => {
+    $$('.mapping-row').forEach(row => {
         const inputs = row.querySelectorAll('input');
         if (inputs[0].value && inputs[1].value) {
             mappings.push({ prefix: inputs[0].value, dir: inputs[1].value });
         }
     });
+    return mappings;
+}
+
+// Save mappings
+$('#btnMappingsSave').addEventListener('click', async () => {
+    const mappings = currentMappings();
 
     try {
         await api('/api/settings', { mappings });
@@ -144,16 +225,93 @@ $('#btnMappingsSave').addEventListener('click', async () => {
     }
 });
 
+$('#jobsInput').addEventListener('change', async () => {
+    const jobs = Math.max(0, parseInt($('#jobsInput').value, 10) || 0);
+    try {
+        await api('/api/settings', { jobs });
+        setStatus(`Parallel workers set to ${jobs || 'number of CPUs'}`);
+    } catch (e) {
+        setStatus('Error saving settings: ' + e.message);
+    }
+});
+
+// Scan patterns, one per line
+function patternLines(text) {
+    return text.split('\n').map(l => l.trim()).filter(Boolean);
+}
+
+['include', 'exclude'].forEach(kind => {
+    $(`#${kind}Input`).addEventListener('change', async () => {
+        try {
+            await api('/api/settings', { [kind]: patternLines($(`#${kind}Input`).value) });
+            setStatus(`${kind === 'include' ? 'Include' : 'Exclude'} patterns saved, rescan to apply`);
+        } catch (e) {
+            setStatus('Error saving settings: ' + e.message);
+        }
+    });
+});
+
+// Export options as set in the toolbar, in .pde.json form
+function currentExport() {
+    return {
+        parseIncludes: $('#parseIncludes').checked,
+        transitive: $('#transitive').checked,
+        budget: parseInt($('#tokenBudget').value) || 0,
+        stubs: $('#stubs').checked,
+        format: $('#exportFormat').value,
+    };
+}
+
+// Apply the settings of a project's .pde.json to the toolbar
+function applyProjectConfig(config) {
+    if (config.framework) $('#framework').value = config.framework;
+    const exp = config.export;
+    if (!exp) return;
+    $('#parseIncludes').checked = !!exp.parseIncludes;
+    $('#transitive').checked = !!exp.transitive;
+    $('#tokenBudget').value = exp.budget || '';
+    $('#stubs').checked = !!exp.stubs;
+    if (exp.format) $('#exportFormat').value = exp.format;
+}
+
+$('#btnSaveProject').addEventListener('click', async () => {
+    try {
+        const data = await api('/api/settings', {
+            mappings: currentMappings(),
+            export: currentExport(),
+            saveProject: true,
+        });
+        setStatus(`Settings saved to ${data.path}`);
+    } catch (e) {
+        setStatus('Error saving project config: ' + e.message);
+    }
+});
+
+$('#extensionsInput').addEventListener('change', async () => {
+    const extensions = $('#extensionsInput').value.split(',').map(e => e.trim()).filter(Boolean);
+    try {
+        await api('/api/settings', { extensions });
+        setStatus(extensions.length ? `Scanning ${extensions.join(' ')} files, rescan to apply` : 'Using the framework default extensions, rescan to apply');
+    } catch (e) {
+        setStatus('Error saving settings: ' + e.message);
+    }
+});
+
 $('#btnAddMapping').addEventListener('click', () => {
     addMappingRow('', '');
 });
 
 function loadSettings() {
-    fetch('/api/settings')
+    fetch(apiUrl('/api/settings'))
         .then(r => r.json())
         .then(data => {
             $('#mappingsList').innerHTML = '';
             (data.mappings || []).forEach(m => addMappingRow(m.prefix, m.dir));
+            $('#jobsInput').value = data.jobs || '';
+            $('#extensionsInput').value = (data.extensions || []).join(', ');
+            $('#extensionsInput').placeholder = (data.defaultExtensions || []).join(', ');
+            $('#includeInput').value = (data.include || []).join('\n');
+            $('#excludeInput').value = (data.exclude || []).join('\n');
         });
 }
 
@@ -174,9 +332,14 @@ function addMappingRow(prefix, dir) {
 // Progress indicator
 // ============================================================
 
-function showProgress(title, detail) {
+// Long-running jobs pass cancellable to show a Cancel button that
+// stops them on the server.
+function showProgress(title, detail, cancellable) {
     $('#progressTitle').textContent = title;
     $('#progressDetail').textContent = detail || '';
+    $('#progressPath').textContent = '';
+    $('#btnCancel').classList.toggle('active', !!cancellable);
+    $('#btnCancel').disabled = false;
     const bar = $('#progressBar');
     bar.style.width = '';
     bar.classList.add('indeterminate');
@@ -193,6 +356,37 @@ function updateProgress(detail, percent) {
     }
 }
 
+const phaseLabels = {
+    scan: 'Reading directories',
+    parse: 'Parsing changed files',
+    index: 'Building class index',
+    references: 'Indexing references',
+    resolve: 'Resolving dependencies',
+};
+
+// Shows a progress event from apiStream in the progress card.
+function reportProgress(e) {
+    const label = phaseLabels[e.phase] || e.phase;
+    const percent = e.total > 0 ? 100 * e.done / e.total : undefined;
+    updateProgress(`${label}: ${e.done} / ${e.total}`, percent);
+    // Truncate long paths from the left so the file name stays readable
+    const p = e.path || '';
+    $('#progressPath').textContent = p.length > 64 ? '\u2026' + p.slice(-63) : p;
+}
+
+$('#btnCancel').addEventListener('click', async () => {
+    $('#btnCancel').disabled = true;
+    updateProgress('Cancelling...');
+    try {
+        await api('/api/cancel', {});
+    } catch (e) {
+        setStatus('Cancel failed: ' + e.message);
+    }
+});
+
+// Jobs stopped through /api/cancel fail with this message
+const cancelledMessage = 'Cancelled';
+
 function hideProgress() {
     $('#progressOverlay').classList.remove('active');
 }
@@ -279,22 +473,30 @@ $('#btnScan').addEventListener('click', async () => {
     const path = state.projectPath;
     if (!path) return;
 
-    showProgress('Scanning Project', 'Traversing directory and building class index...');
+    showProgress('Scanning Project', 'Traversing directory and building class index...', true);
     setStatus('Scanning project...');
     $('#btnScan').disabled = true;
 
     try {
-        const data = await api('/api/scan', {
+        const data = await apiStream('/api/scan', {
             path: path,
             framework: $('#framework').value,
-        });
+        }, reportProgress);
+
+        // Tell side-by-side tabs apart by project name
+        const projectName = path.split('/').filter(Boolean).pop() || path;
+        document.title = `${projectName} - PHP Dependency Extractor`;
+        api('/api/sessions', { name: projectName }).catch(() => {});
+
+        if (data.config) applyProjectConfig(data.config);
 
         state.treeData = data.tree;
         state.selectedFiles.clear();
         state.dependencies = [];
         state.includes = [];
 
-        updateProgress('Building file tree...', 80);
+        $('#progressPath').textContent = '';
+        updateProgress('Building file tree...', 100);
 
         renderTree(data.tree);
         renderResults();
@@ -303,38 +505,50 @@ $('#btnScan').addEventListener('click', async () => {
         updateProgress('Done!', 100);
         setTimeout(hideProgress, 400);
 
-        setStatus(`Scanned ${data.fileCount} files, indexed ${data.indexed} classes`);
+        const reused = data.cache && data.cache.reused ? ` (${data.cache.reused} unchanged since the last scan)` : '';
+        const functions = data.functions ? ` and ${data.functions} functions` : '';
+        loadPresets();
+        setStatus(`Scanned ${data.fileCount} files${reused}, indexed ${data.indexed} classes${functions}` + (data.indexSource === 'composer' ? ' (composer.json autoload)' : '') + (data.config ? ', settings from .pde.json' : '') + (data.warnings ? ' | Warning: ' + data.warnings.join('; ') : ''));
         $('#btnAnalyze').disabled = false;
     } catch (e) {
         hideProgress();
-        setStatus('Scan error: ' + e.message);
+        setStatus(e.message === cancelledMessage ? 'Scan cancelled' : 'Scan error: ' + e.message);
     } finally {
         $('#btnScan').disabled = false;
     }
 });
 
-$('#btnAnalyze').addEventListener('click', async () => {
+$('#btnAnalyze').addEventListener('click', analyze);
+
+// analyze resolves the dependencies of the selected files and reports
+// whether it succeeded.
+async function analyze() {
     if (state.selectedFiles.size === 0) {
         setStatus('No files selected');
-        return;
+        return false;
     }
 
     const fileCount = state.selectedFiles.size;
-    showProgress('Analyzing Dependencies', `Parsing ${fileCount} selected file${fileCount > 1 ? 's' : ''}...`);
+    showProgress('Analyzing Dependencies', `Parsing ${fileCount} selected file${fileCount > 1 ? 's' : ''}...`, true);
     setStatus('Analyzing dependencies...');
     $('#btnAnalyze').disabled = true;
 
     try {
-        const data = await api('/api/analyze', {
+        const data = await apiStream('/api/analyze', {
             files: Array.from(state.selectedFiles),
             parseIncludes: $('#parseIncludes').checked,
-        });
+            transitive: $('#transitive').checked,
+            budget: parseInt($('#tokenBudget').value) || 0,
+            stubs: $('#stubs').checked,
+        }, reportProgress);
 
         state.dependencies = data.dependencies || [];
+        state.tokens = data.tokens || null;
         state.includes = data.includes || [];
         state.checkedIncludes.clear();
 
-        updateProgress('Rendering results...', 90);
+        $('#progressPath').textContent = '';
+        updateProgress('Rendering results...', 100);
 
         renderResults();
         updateCopyButton();
@@ -346,13 +560,37 @@ $('#btnAnalyze').addEventListener('click', async () => {
         setTimeout(hideProgress, 400);
 
         setStatus(`Found ${depCount} dependencies` + (incCount > 0 ? `, ${incCount} includes` : ''));
+        return true;
     } catch (e) {
         hideProgress();
-        setStatus('Analysis error: ' + e.message);
+        setStatus(e.message === cancelledMessage ? 'Analysis cancelled' : 'Analysis error: ' + e.message);
+        return false;
     } finally {
         $('#btnAnalyze').disabled = false;
     }
-});
+}
+
+// estimateIncludes re-estimates the tokens of the last analysis with the
+// ticked includes, which are exported and so count towards the budget.
+async function estimateIncludes() {
+    if (!state.tokens) return;
+    const includes = new Set();
+    state.checkedIncludes.forEach(idx => {
+        const inc = state.includes[idx];
+        if (inc && inc.resolved) includes.add(inc.resolved);
+    });
+    try {
+        state.tokens = await api('/api/estimate', {
+            includes: Array.from(includes),
+            budget: parseInt($('#tokenBudget').value) || 0,
+            stubs: $('#stubs').checked,
+        });
+        renderResults();
+        updateCopyButton();
+    } catch (e) {
+        setStatus('Estimate error: ' + e.message);
+    }
+}
 
 $('#btnCopy').addEventListener('click', async () => {
     const outputDir = getEffectiveOutputPath();
@@ -372,9 +610,12 @@ $('#btnCopy').addEventListener('click', async () => {
     $('#btnCopy').disabled = true;
 
     try {
+        const plan = state.tokens && state.tokens.plan;
         const data = await api('/api/copy', {
             files: files,
             outputDir: outputDir,
+            format: $('#exportFormat').value,
+            stubbed: plan ? (plan.stubbed || []) : [],
         });
 
         const copied = (data.copied || []).length;
@@ -383,7 +624,8 @@ $('#btnCopy').addEventListener('click', async () => {
         updateProgress(`Copied ${copied} files`, 100);
         setTimeout(hideProgress, 500);
 
-        setStatus(`Copied ${copied} files to ${outputDir}` + (errors > 0 ? ` (${errors} errors)` : ''));
+        const target = data.file || outputDir;
+        setStatus(`Copied ${copied} files to ${target}` + (errors > 0 ? ` (${errors} errors)` : ''));
     } catch (e) {
         hideProgress();
         setStatus('Copy error: ' + e.message);
@@ -392,6 +634,81 @@ $('#btnCopy').addEventListener('click', async () => {
     }
 });
 
+// ============================================================
+// Selection presets, stored in the project's .pde.json
+// ============================================================
+
+async function loadPresets() {
+    const select = $('#presetSelect');
+    select.innerHTML = '<option value="">Presets</option>';
+    try {
+        const resp = await fetch(apiUrl('/api/presets'));
+        const data = await resp.json();
+        if (!resp.ok) throw new Error(data.error);
+        state.presets = data.presets;
+    } catch (e) {
+        state.presets = [];
+        setStatus('Error loading presets: ' + e.message);
+    }
+    state.presets.forEach(p => {
+        const opt = document.createElement('option');
+        opt.value = p.name;
+        opt.textContent = `${p.name} (${p.files.length} file${p.files.length > 1 ? 's' : ''})`;
+        select.appendChild(opt);
+    });
+    select.disabled = state.presets.length === 0;
+    $('#btnSavePreset').disabled = false;
+}
+
+// Replay a preset: select its files, apply its options, analyze and tick
+// its includes, so Copy Files regenerates the saved export.
+$('#presetSelect').addEventListener('change', async (e) => {
+    const preset = (state.presets || []).find(p => p.name === e.target.value);
+    e.target.value = '';
+    if (!preset) return;
+
+    state.selectedFiles = new Set(preset.files);
+    if (preset.export) applyProjectConfig({ export: preset.export });
+    renderTree(state.treeData);
+    if (!await analyze()) return;
+
+    const ticked = new Set(preset.includes || []);
+    state.includes.forEach((inc, idx) => {
+        if (inc.resolved && ticked.has(inc.resolved)) state.checkedIncludes.add(idx);
+    });
+    renderResults();
+    updateCopyButton();
+    await estimateIncludes();
+    setStatus(`Preset "${preset.name}" loaded: ${state.dependencies.length} dependencies. Click Copy Files to regenerate the export.`);
+});
+
+$('#btnSavePreset').addEventListener('click', async () => {
+    if (state.selectedFiles.size === 0) {
+        setStatus('Select files to save as a preset');
+        return;
+    }
+    const name = (prompt('Preset name:') || '').trim();
+    if (!name) return;
+
+    const includes = [];
+    state.checkedIncludes.forEach(idx => {
+        const inc = state.includes[idx];
+        if (inc && inc.resolved) includes.push(inc.resolved);
+    });
+    try {
+        const data = await api('/api/presets', {
+            name,
+            files: Array.from(state.selectedFiles).sort(),
+            includes,
+            export: currentExport(),
+        });
+        await loadPresets();
+        setStatus(`Preset "${name}" saved to ${data.path}`);
+    } catch (e) {
+        setStatus('Error saving preset: ' + e.message);
+    }
+});
+
 // Search filter
 $('#searchFilter').addEventListener('input', (e) => {
     state.searchFilter = e.target.value.toLowerCase();
@@ -575,7 +892,7 @@ function renderResults() {
         selectedArr.forEach(path => {
             const item = document.createElement('div');
             item.className = 'file-item';
-            item.innerHTML = `<span class="file-path">${escHtml(path)}</span>`;
+            item.innerHTML = `<span class="file-path">${escHtml(path)}</span><span class="file-ref">${tokenLabel(path)}</span>`;
             section.appendChild(item);
         });
 
@@ -593,9 +910,11 @@ function renderResults() {
         deps.forEach(dep => {
             const item = document.createElement('div');
             item.className = 'file-item';
+            const fate = planFate(dep.filePath);
+            if (fate === 'dropped') item.classList.add('dropped');
             item.innerHTML = `
-                <span class="file-path">${escHtml(dep.filePath)}</span>
-                <span class="file-ref">${escHtml(dep.className)} (${dep.refType}) from ${escHtml(shortPath(dep.referencedBy))}</span>
+                <span class="file-path">${escHtml(dep.filePath)}${fate ? ` <span class="badge badge-gray">${fate}</span>` : ''}</span>
+                <span class="file-ref">${escHtml(dep.className)} (${dep.refType}) from ${escHtml(shortPath(dep.referencedBy))}${dep.depth > 1 ? ` [depth ${dep.depth}]` : ''}${tokenLabel(dep.filePath)}</span>
             `;
             section.appendChild(item);
         });
@@ -622,6 +941,7 @@ function renderResults() {
                 if (cb.checked) state.checkedIncludes.add(idx);
                 else state.checkedIncludes.delete(idx);
                 updateCopyButton();
+                estimateIncludes();
             });
 
             const typeSpan = document.createElement('span');
@@ -649,7 +969,30 @@ function renderResults() {
     }
 
     const totalDeps = deps.length;
-    $('#depCount').textContent = totalDeps > 0 ? `${totalDeps} deps` : '';
+    let countText = totalDeps > 0 ? `${totalDeps} deps` : '';
+    if (state.tokens) {
+        const plan = state.tokens.plan;
+        countText += ` | ~${state.tokens.total.toLocaleString()} tokens`;
+        if (plan) {
+            countText += ` (budget ${plan.budget.toLocaleString()}: ~${plan.total.toLocaleString()}${plan.fits ? '' : ', over'})`;
+        }
+    }
+    $('#depCount').textContent = countText;
+}
+
+// tokenLabel returns the estimated token count of a file for display.
+function tokenLabel(path) {
+    const f = state.tokens && (state.tokens.files || []).find(f => f.path === path);
+    return f ? ` ~${f.tokens.toLocaleString()} tokens` : '';
+}
+
+// planFate returns "stubbed" or "dropped" when the token budget plan reduced a file.
+function planFate(path) {
+    const plan = state.tokens && state.tokens.plan;
+    if (!plan) return '';
+    if ((plan.dropped || []).includes(path)) return 'dropped';
+    if ((plan.stubbed || []).includes(path)) return 'stubbed';
+    return '';
 }
 
 // ============================================================
@@ -658,10 +1001,12 @@ function renderResults() {
 
 function getAllCopyFiles() {
     const files = new Set(state.selectedFiles);
-    (state.dependencies || []).forEach(dep => files.add(dep.filePath));
+    (state.dependencies || []).forEach(dep => {
+        if (planFate(dep.filePath) !== 'dropped') files.add(dep.filePath);
+    });
     state.checkedIncludes.forEach(idx => {
         const inc = state.includes[idx];
-        if (inc && inc.resolved) files.add(inc.resolved);
+        if (inc && inc.resolved && planFate(inc.resolved) !== 'dropped') files.add(inc.resolved);
     });
     return Array.from(files);
 }